@@ -3,18 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
-	"hash"
 	"log"
 
 	ch "github.com/ArchishmanSengupta/consistent-hashing"
-	"github.com/spaolacci/murmur3"
 )
 
-// stand by for now
-func customMurmurHash() hash.Hash64 {
-	return murmur3.New64()
-}
-
 // printLoads prints the current load of all hosts
 func printLoads(c *ch.ConsistentHashing) {
 	fmt.Println("Current loads:")
@@ -32,7 +25,7 @@ func main() {
 	cfg := ch.Config{
 		ReplicationFactor: 3,
 		LoadFactor:        1.5,
-		HashFunction:      customMurmurHash,
+		HashName:          "murmur3", // see ch.HashNames() for the built-in hash functions
 	}
 
 	hashRing, err := ch.NewWithConfig(cfg)
@@ -0,0 +1,107 @@
+package consistent_hashing
+
+import (
+	"context"
+	"encoding/binary"
+	"hash"
+)
+
+// Balancer is the common interface of the placement algorithms in this package.
+// It lets callers switch algorithms per workload without changing how they look up keys.
+type Balancer interface {
+	// Add adds a host to the balancer. It returns ErrHostExists if the host is already present.
+	Add(ctx context.Context, host string) error
+	// Remove removes a host from the balancer.
+	Remove(ctx context.Context, host string) error
+	// Get retrieves the host that should handle the given key.
+	Get(ctx context.Context, key string) (string, error)
+	// GetN retrieves the first n distinct hosts for the given key in preference order.
+	// It returns ErrInvalidReplicaCount for a negative n and ErrInsufficientHosts if n exceeds the no of hosts.
+	GetN(ctx context.Context, key string, n int) ([]string, error)
+	// Hosts returns the list of current hosts.
+	Hosts() []string
+}
+
+// Algorithm selects the placement algorithm built by NewBalancer.
+type Algorithm int
+
+const (
+	// AlgorithmRing is the virtual node ring with bounded loads implemented by ConsistentHashing.
+	AlgorithmRing Algorithm = iota
+	// AlgorithmJump is Jump Consistent Hash (Lamping & Veach).
+	AlgorithmJump
+	// AlgorithmRendezvous is Rendezvous or highest random weight (HRW) hashing.
+	AlgorithmRendezvous
+	// AlgorithmMaglev is Maglev hashing with a fixed size lookup table.
+	AlgorithmMaglev
+	// AlgorithmMultiProbe is multi-probe consistent hashing with one point per host.
+	AlgorithmMultiProbe
+)
+
+// Compile time checks that every algorithm implements Balancer.
+var (
+	_ Balancer = (*ConsistentHashing)(nil)
+	_ Balancer = (*JumpHash)(nil)
+	_ Balancer = (*Rendezvous)(nil)
+	_ Balancer = (*Maglev)(nil)
+	_ Balancer = (*MultiProbe)(nil)
+)
+
+// NewBalancer creates the Balancer selected by cfg.Algorithm.
+// It returns ErrUnknownAlgorithm if the algorithm is not supported.
+func NewBalancer(cfg Config) (Balancer, error) {
+	switch cfg.Algorithm {
+	case AlgorithmRing:
+		return NewWithConfig(cfg)
+	case AlgorithmJump:
+		return NewJumpHash(cfg)
+	case AlgorithmRendezvous:
+		return NewRendezvous(cfg)
+	case AlgorithmMaglev:
+		return NewMaglev(cfg)
+	case AlgorithmMultiProbe:
+		return NewMultiProbe(cfg)
+	default:
+		return nil, ErrUnknownAlgorithm
+	}
+}
+
+// --------------------------------- Helper Functions ---------------------------------
+
+// hashWith generates a 64-bit hash value for key using the given hash function.
+func hashWith(fn func() hash.Hash64, key string) uint64 {
+	h := fn()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// hashSeeded generates a 64-bit hash value for key salted with seed, so a single hash
+// function can produce a family of independent hashes for the same key.
+func hashSeeded(fn func() hash.Hash64, key string, seed uint64) uint64 {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], seed)
+	h := fn()
+	h.Write([]byte(key))
+	h.Write(buf[:])
+	return h.Sum64()
+}
+
+// mix64 is the splitmix64 finalizer, used to scramble combined hash values.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// indexOf returns the position of host in hosts, or -1 if it is not present.
+func indexOf(hosts []string, host string) int {
+	for i, h := range hosts {
+		if h == host {
+			return i
+		}
+	}
+	return -1
+}
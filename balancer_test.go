@@ -0,0 +1,124 @@
+package consistent_hashing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"testing"
+)
+
+var algorithms = map[string]Algorithm{
+	"ring":       AlgorithmRing,
+	"jump":       AlgorithmJump,
+	"rendezvous": AlgorithmRendezvous,
+	"maglev":     AlgorithmMaglev,
+	"multiprobe": AlgorithmMultiProbe,
+}
+
+func TestNewBalancer(t *testing.T) {
+	if _, err := NewBalancer(Config{Algorithm: Algorithm(42)}); err != ErrUnknownAlgorithm {
+		t.Errorf("Expected ErrUnknownAlgorithm, got %v", err)
+	}
+	b, err := NewBalancer(Config{})
+	if err != nil {
+		t.Fatalf("NewBalancer failed: %v", err)
+	}
+	if _, ok := b.(*ConsistentHashing); !ok {
+		t.Errorf("Expected the ring by default, got %T", b)
+	}
+}
+
+func TestBalancers(t *testing.T) {
+	for name, algorithm := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			b, err := NewBalancer(Config{Algorithm: algorithm, ReplicationFactor: 100, HashFunction: fnv.New64a, MaglevTableSize: 1031})
+			if err != nil {
+				t.Fatalf("NewBalancer failed: %v", err)
+			}
+			ctx := context.Background()
+			if _, err := b.Get(ctx, "key1"); err != ErrNoHost {
+				t.Errorf("Expected ErrNoHost, got %v", err)
+			}
+
+			hosts := []string{"host1", "host2", "host3", "host4", "host5"}
+			for _, host := range hosts {
+				if err := b.Add(ctx, host); err != nil {
+					t.Fatalf("Add failed: %v", err)
+				}
+			}
+			if err := b.Add(ctx, "host1"); !errors.Is(err, ErrHostExists) {
+				t.Errorf("Expected ErrHostExists, got %v", err)
+			}
+			if err := b.Add(ctx, ""); !errors.Is(err, ErrInvalidHost) {
+				t.Errorf("Expected ErrInvalidHost, got %v", err)
+			}
+			if len(b.Hosts()) != len(hosts) {
+				t.Errorf("Expected %d hosts, got %v", len(hosts), b.Hosts())
+			}
+
+			counts := make(map[string]int)
+			for i := 0; i < 5000; i++ {
+				key := fmt.Sprintf("key%d", i)
+				host, err := b.Get(ctx, key)
+				if err != nil {
+					t.Fatalf("Get failed: %v", err)
+				}
+				counts[host]++
+
+				replicas, err := b.GetN(ctx, key, 3)
+				if err != nil {
+					t.Fatalf("GetN failed: %v", err)
+				}
+				if replicas[0] != host {
+					t.Fatalf("Expected first replica %s, got %v", host, replicas)
+				}
+				if replicas[1] == replicas[0] || replicas[2] == replicas[0] || replicas[1] == replicas[2] {
+					t.Fatalf("Expected distinct replicas, got %v", replicas)
+				}
+			}
+			for _, host := range hosts {
+				if counts[host] == 0 {
+					t.Errorf("Host %s got none of the keys", host)
+				}
+			}
+
+			if _, err := b.GetN(ctx, "key1", 6); err != ErrInsufficientHosts {
+				t.Errorf("Expected ErrInsufficientHosts, got %v", err)
+			}
+			if _, err := b.GetN(ctx, "key1", -1); err != ErrInvalidReplicaCount {
+				t.Errorf("Expected ErrInvalidReplicaCount, got %v", err)
+			}
+			if err := b.Remove(ctx, "host6"); err != ErrHostNotFound {
+				t.Errorf("Expected ErrHostNotFound, got %v", err)
+			}
+			if err := b.Remove(ctx, "host3"); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			for i := 0; i < 1000; i++ {
+				if host, _ := b.Get(ctx, fmt.Sprintf("key%d", i)); host == "host3" {
+					t.Fatalf("Removed host3 still receives keys")
+				}
+			}
+		})
+	}
+}
+
+// assertMinimalRemoval checks that removing a host only moves the keys it owned.
+func assertMinimalRemoval(t *testing.T, b Balancer) {
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		b.Add(ctx, fmt.Sprintf("host%d", i))
+	}
+	before := make(map[string]string)
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		before[key], _ = b.Get(ctx, key)
+	}
+	b.Remove(ctx, "host4")
+	for key, old := range before {
+		if host, _ := b.Get(ctx, key); old != "host4" && host != old {
+			t.Errorf("Key %s moved from %s to %s", key, old, host)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+)
+
+// ChangeOp is the kind of membership change applied by Apply.
+type ChangeOp int
+
+const (
+	// ChangeAdd adds a host with the weight, topology labels and tokens of the change options.
+	ChangeAdd ChangeOp = iota + 1
+	// ChangeRemove removes a host.
+	ChangeRemove
+	// ChangeWeight changes the weight of a host to the weight of the change options.
+	ChangeWeight
+)
+
+// String returns the name of the change operation.
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeAdd:
+		return "Add"
+	case ChangeRemove:
+		return "Remove"
+	case ChangeWeight:
+		return "Weight"
+	default:
+		return "Unknown"
+	}
+}
+
+// Change is a single membership or weight change of a batch applied with Apply.
+type Change struct {
+	Op      ChangeOp    // kind of change
+	Host    string      // host the change applies to
+	Options HostOptions // weight, topology labels and tokens for ChangeAdd, weight for ChangeWeight
+}
+
+// AddHosts adds all hosts with a weight of 1 in a single atomic update, see Apply.
+func (c *ConsistentHashing) AddHosts(ctx context.Context, hosts ...string) error {
+	changes := make([]Change, 0, len(hosts))
+	for _, host := range hosts {
+		changes = append(changes, Change{Op: ChangeAdd, Host: host})
+	}
+	return c.Apply(ctx, changes)
+}
+
+// RemoveHosts removes all hosts in a single atomic update, see Apply.
+func (c *ConsistentHashing) RemoveHosts(ctx context.Context, hosts ...string) error {
+	changes := make([]Change, 0, len(hosts))
+	for _, host := range hosts {
+		changes = append(changes, Change{Op: ChangeRemove, Host: host})
+	}
+	return c.Apply(ctx, changes)
+}
+
+// Apply applies the changes in order as a single transaction. Every change is validated against
+// the ring as left by the changes before it, and nothing is changed if any of them fails, with
+// the same errors as Add, Remove and UpdateWeight. The new ring is built once and published
+// atomically, so readers never observe a partially applied batch. A non-empty batch bumps the
+// ring version once and emits a single EventBatchApplied event listing the changes.
+func (c *ConsistentHashing) Apply(ctx context.Context, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Validate the changes in order against a working copy of the membership.
+	r := c.current()
+	hosts := append([]*Host(nil), r.hosts...)
+	weights := append([]int(nil), r.weights...)
+	index := make(map[string]int, len(hosts))
+	for i, h := range hosts {
+		index[h.Name] = i
+	}
+	for _, change := range changes {
+		weight := change.Options.Weight
+		switch change.Op {
+		case ChangeAdd:
+			if change.Host == "" {
+				return ErrInvalidHost
+			}
+			if weight == 0 {
+				weight = 1
+			}
+			if weight < 0 {
+				return ErrInvalidWeight
+			}
+			if _, ok := index[change.Host]; ok {
+				return fmt.Errorf("%w: %s", ErrHostExists, change.Host)
+			}
+			index[change.Host] = len(hosts)
+			hosts = append(hosts, &Host{
+				Name:   change.Host,
+				Region: change.Options.Region,
+				Zone:   change.Options.Zone,
+				Rack:   change.Options.Rack,
+				Tokens: copyTokens(change.Options.Tokens),
+			})
+			weights = append(weights, weight)
+		case ChangeRemove:
+			i, ok := index[change.Host]
+			if !ok {
+				return ErrHostNotFound
+			}
+			delete(index, change.Host)
+			hosts = append(hosts[:i], hosts[i+1:]...)
+			weights = append(weights[:i], weights[i+1:]...)
+			for k := i; k < len(hosts); k++ {
+				index[hosts[k].Name] = k
+			}
+		case ChangeWeight:
+			if weight <= 0 {
+				return ErrInvalidWeight
+			}
+			i, ok := index[change.Host]
+			if !ok {
+				return ErrHostNotFound
+			}
+			weights[i] = weight
+		default:
+			return fmt.Errorf("%w: %d", ErrUnknownChange, change.Op)
+		}
+	}
+
+	// Build the new ring once and publish it.
+	next, err := r.withHosts(hosts, weights)
+	if err != nil {
+		return err
+	}
+	c.ring.Store(next)
+	kept := make(map[*Host]struct{}, len(next.hosts))
+	for _, h := range next.hosts {
+		kept[h] = struct{}{}
+	}
+
+	// Take the loads of the removed hosts out of the total load.
+	for _, h := range r.hosts {
+		if _, ok := kept[h]; !ok {
+			c.retire(h)
+		}
+	}
+
+	// Notify subscribers about the whole batch at once.
+	c.publish(RingEvent{
+		Type:    EventBatchApplied,
+		Version: c.bumpVersion(),
+		Changes: append([]Change(nil), changes...),
+	})
+
+	return nil
+}
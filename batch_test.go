@@ -0,0 +1,117 @@
+package consistent_hashing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"testing"
+)
+
+func TestAddHosts(t *testing.T) {
+	ctx := context.Background()
+	batch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	single, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+
+	hosts := make([]string, 50)
+	for i := range hosts {
+		hosts[i] = fmt.Sprintf("host%d", i)
+		single.Add(ctx, hosts[i])
+	}
+	if err := batch.AddHosts(ctx, hosts...); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A batch places keys exactly like the same hosts added one by one, with a single version bump.
+	if batch.Version() != 1 {
+		t.Errorf("Expected version 1, got %d", batch.Version())
+	}
+	if stats := batch.Stats(); stats.Hosts != 50 || stats.VNodes != 500 {
+		t.Errorf("Expected 50 hosts and 500 vnodes, got %+v", stats)
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		x, _ := batch.Get(ctx, key)
+		y, _ := single.Get(ctx, key)
+		if x != y {
+			t.Fatalf("Key %s: %s in batch ring, %s in single ring", key, x, y)
+		}
+	}
+
+	if err := batch.RemoveHosts(ctx, hosts[10:]...); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(batch.Hosts(), hosts[:10]) || batch.Version() != 2 {
+		t.Errorf("Expected hosts %v at version 2, got %v at version %d", hosts[:10], batch.Hosts(), batch.Version())
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.AddHosts(ctx, "host1", "host2")
+	events := ch.Subscribe(ctx)
+
+	changes := []Change{
+		{Op: ChangeAdd, Host: "host3", Options: HostOptions{Weight: 2, Zone: "a"}},
+		{Op: ChangeRemove, Host: "host1"},
+		{Op: ChangeWeight, Host: "host2", Options: HostOptions{Weight: 3}},
+	}
+	if err := ch.Apply(ctx, changes); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(ch.Hosts(), []string{"host2", "host3"}) {
+		t.Errorf("Expected [host2 host3], got %v", ch.Hosts())
+	}
+	if stats := ch.Stats(); stats.VNodes != 50 {
+		t.Errorf("Expected 50 vnodes, got %d", stats.VNodes)
+	}
+	if weights := ch.ring.Load().weights; !reflect.DeepEqual(weights, []int{3, 2}) {
+		t.Errorf("Expected weights [3 2], got %v", weights)
+	}
+
+	want := RingEvent{Type: EventBatchApplied, Version: 2, Changes: changes}
+	if got := <-events; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("Expected a single event, got %+v", ev)
+	default:
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.Add(ctx, "host1")
+
+	tests := []struct {
+		changes []Change
+		err     error
+	}{
+		{[]Change{{Op: ChangeAdd, Host: "host2"}, {Op: ChangeAdd, Host: "host1"}}, ErrHostExists},
+		{[]Change{{Op: ChangeAdd, Host: "host2"}, {Op: ChangeAdd, Host: "host2"}}, ErrHostExists},
+		{[]Change{{Op: ChangeAdd, Host: ""}}, ErrInvalidHost},
+		{[]Change{{Op: ChangeAdd, Host: "host2", Options: HostOptions{Weight: -1}}}, ErrInvalidWeight},
+		{[]Change{{Op: ChangeRemove, Host: "host1"}, {Op: ChangeRemove, Host: "host1"}}, ErrHostNotFound},
+		{[]Change{{Op: ChangeWeight, Host: "host1", Options: HostOptions{Weight: 0}}}, ErrInvalidWeight},
+		{[]Change{{Op: ChangeAdd, Host: "host2"}, {Op: 0, Host: "host2"}}, ErrUnknownChange},
+	}
+	for _, tt := range tests {
+		if err := ch.Apply(ctx, tt.changes); !errors.Is(err, tt.err) {
+			t.Errorf("Expected %v for %+v, got %v", tt.err, tt.changes, err)
+		}
+	}
+
+	// Failed batches leave the ring untouched.
+	if !reflect.DeepEqual(ch.Hosts(), []string{"host1"}) || ch.Version() != 1 {
+		t.Errorf("Expected the ring to be unchanged, got %v at version %d", ch.Hosts(), ch.Version())
+	}
+
+	// Removing and re-adding a host in the same batch is allowed.
+	if err := ch.Apply(ctx, []Change{{Op: ChangeRemove, Host: "host1"}, {Op: ChangeAdd, Host: "host1"}}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"hash"
 	"hash/fnv"
-	"log"
 	"math"
 	"sort"
 	"sync"
@@ -15,293 +14,502 @@ import (
 
 // Custom errors
 var (
-	ErrNoHost       = errors.New("no host added")
-	ErrHostNotFound = errors.New("host not found")
+	ErrNoHost                = errors.New("no host added")
+	ErrHostNotFound          = errors.New("host not found")
+	ErrInvalidWeight         = errors.New("host weight must be positive")
+	ErrInsufficientHosts     = errors.New("not enough hosts for the requested replica count")
+	ErrInvalidTableSize      = errors.New("maglev table size must be a prime larger than the number of hosts")
+	ErrUnknownAlgorithm      = errors.New("unknown placement algorithm")
+	ErrUnknownHash           = errors.New("unknown hash function")
+	ErrHashRegistered        = errors.New("hash function already registered")
+	ErrInvalidSnapshot       = errors.New("invalid ring snapshot")
+	ErrSnapshotVersion       = errors.New("unsupported ring snapshot format version")
+	ErrSnapshotMismatch      = errors.New("ring snapshot does not match recomputed virtual nodes")
+	ErrHostExists            = errors.New("host already exists")
+	ErrInvalidHost           = errors.New("invalid host name")
+	ErrHashFailed            = errors.New("key hashing failed")
+	ErrVNodeCollision        = errors.New("virtual node hash collision")
+	ErrUnknownChange         = errors.New("unknown ring change operation")
+	ErrInvalidSeed           = errors.New("invalid hash seed")
+	ErrUnknownVNodeScheme    = errors.New("unknown vnode naming scheme")
+	ErrVNodeSchemeRegistered = errors.New("vnode naming scheme already registered")
+	ErrInvalidTokens         = errors.New("no tokens given")
+	ErrNegativeLoad          = errors.New("host load must not be negative")
+	ErrLoadMismatch          = errors.New("total load does not match the sum of host loads")
+	ErrInvalidHealthConfig   = errors.New("invalid health check config")
+	ErrInvalidReplicaCount   = errors.New("replica count must not be negative")
 )
 
 // Consistent Hashing config parameters
 type Config struct {
-	ReplicationFactor int                // no of virtual_nodes per host
-	LoadFactor        float64            // max load factor before redistribution
-	HashFunction      func() hash.Hash64 // for the time being lets keep the hash function simple
+	ReplicationFactor int                             // no of virtual_nodes per unit of host weight
+	LoadFactor        float64                         // max load factor before redistribution
+	HashFunction      func() hash.Hash64              // hash objects are pooled and Reset between keys
+	HashName          string                          // name of a registered hash function, used if HashFunction is nil
+	HashSeed          []byte                          // secret key or seed for the keyed hash function named by HashName
+	Strategy          Strategy                        // how GetLeast and GetLeastN pick a host under load
+	Algorithm         Algorithm                       // placement algorithm built by NewBalancer
+	MaglevTableSize   int                             // size of the Maglev lookup table, must be prime
+	MultiProbeCount   int                             // no of key probes for multi-probe consistent hashing
+	Ketama            bool                            // libmemcached-compatible ketama placement, ignores ReplicationFactor, the hash function and VNodeKey
+	VNodeKey          func(host string, i int) string // derives the key hashed to place the i-th virtual node of a host
+	VNodeScheme       string                          // name of a registered vnode naming scheme, used if VNodeKey is nil
 }
 
+// Strategy selects how load-aware lookups pick a host for a key.
+type Strategy int
+
+const (
+	// StrategyBoundedLoad picks the first host clockwise from the key whose load is acceptable.
+	// This preserves key locality and only spills keys over to neighbours of a saturated host.
+	StrategyBoundedLoad Strategy = iota
+	// StrategyLeastLoaded picks the host with the least load relative to its weight among all
+	// hosts with acceptable load, regardless of where the key falls on the ring.
+	StrategyLeastLoaded
+)
+
 // Host is a physical node in the CH hashing ring
 type Host struct {
-	Name string // HostName or identifier
-	Load int64  // current load on the host
+	Name   string   // HostName or identifier
+	Load   int64    // current load on the host
+	Region string   // topology label: region the host lives in
+	Zone   string   // topology label: availability zone within the region
+	Rack   string   // topology label: rack within the zone
+	Tokens []uint64 // explicit virtual node positions, nil if the positions are hashed from the name
+
+	down int32 // 1 while the host is marked unhealthy, see SetHealthy
 }
 
-// CH with bounded loads
+// HostOptions are the optional properties of a host added with AddWithOptions
+type HostOptions struct {
+	Weight int      // relative capacity of the host, defaults to 1
+	Region string   // region the host lives in
+	Zone   string   // availability zone within the region
+	Rack   string   // rack within the zone
+	Tokens []uint64 // explicit virtual node positions, see AddWithTokens
+}
+
+// CH with bounded loads.
+// The hosts and virtual nodes live in an immutable ring that writers replace atomically,
+// so lookups are a single atomic load plus a binary search and never block.
 type ConsistentHashing struct {
-	config    Config
-	hosts     sync.Map     // Map of hash value to host
-	sortedSet []uint64     // sorted slice of hash values
-	loadMap   sync.Map     // map of host to Host struct
-	totalLoad int64        // total load across all hosts
-	hostList  []string     // list of all hosts ['uat-server.something.com', 'be-server.something.com']
-	mu        sync.RWMutex // Mutex for synchronizing access
+	ring      atomic.Pointer[ring]     // current immutable ring, swapped on every membership, weight or health change
+	totalLoad int64                    // total load across all hosts
+	mu        sync.Mutex               // Mutex for serializing writers, readers never lock
+	version   uint64                   // ring version, bumped on every membership or weight change
+	subs      subscribers              // subscribers to ring events
+	observer  atomic.Pointer[Observer] // observer of lookups and ring events, nil if unset
 }
 
+// maxProbes is the no of salted re-probes tried for a colliding virtual node before giving up.
+const maxProbes = 64
+
 // New CH instance
 func NewWithConfig(cfg Config) (*ConsistentHashing, error) {
-	if cfg.ReplicationFactor <= 0 {
-		cfg.ReplicationFactor = 10
+	cfg, err := withDefaults(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	if cfg.LoadFactor <= 1 {
-		cfg.LoadFactor = 1.25
-	}
+	c := &ConsistentHashing{}
+	c.ring.Store(emptyRing(cfg))
+	return c, nil
+}
 
-	if cfg.HashFunction == nil {
-		cfg.HashFunction = fnv.New64a
+// current returns the current ring. A zero ConsistentHashing starts out with an empty ring
+// using the default config, so it behaves like a ring created with NewWithConfig(Config{}).
+func (c *ConsistentHashing) current() *ring {
+	if r := c.ring.Load(); r != nil {
+		return r
 	}
-
-	return &ConsistentHashing{
-		config:    cfg,
-		sortedSet: make([]uint64, 0),
-	}, nil
+	cfg, _ := withDefaults(Config{})
+	c.ring.CompareAndSwap(nil, emptyRing(cfg))
+	return c.ring.Load()
 }
 
 // Add adds a new host to the consistent hashing ring, including its virtual nodes,
 // and updates the internal data structures accordingly. It returns an error if the operation fails.
+// The host is added with a weight of 1, see AddWithWeight for hosts of different capacity.
+// It returns ErrInvalidHost for an empty host name, ErrHostExists if the host is already in the ring,
+// ErrHashFailed if a virtual node can not be hashed and ErrVNodeCollision if a virtual node lands on
+// the position of another virtual node. The ring is left unchanged on error.
 func (c *ConsistentHashing) Add(ctx context.Context, host string) error {
-	// Acquire a lock to ensure thread safety during the update.
+	return c.AddWithWeight(ctx, host, 1)
+}
+
+// AddWithWeight adds a new host with the given weight to the consistent hashing ring.
+// The host receives ReplicationFactor * weight virtual nodes, so its share of the keys
+// and its bounded-load capacity are proportional to its weight.
+// It returns ErrInvalidWeight if weight is not positive.
+func (c *ConsistentHashing) AddWithWeight(ctx context.Context, host string, weight int) error {
+	if weight <= 0 {
+		return ErrInvalidWeight
+	}
+	return c.AddWithOptions(ctx, host, HostOptions{Weight: weight})
+}
+
+// AddWithOptions adds a new host with the given weight and topology labels to the consistent
+// hashing ring. A zero weight defaults to 1, a negative weight returns ErrInvalidWeight.
+func (c *ConsistentHashing) AddWithOptions(ctx context.Context, host string, opts HostOptions) error {
+	if host == "" {
+		return ErrInvalidHost
+	}
+	weight := opts.Weight
+	if weight == 0 {
+		weight = 1
+	}
+	if weight < 0 {
+		return ErrInvalidWeight
+	}
+
+	// Acquire the writer lock so concurrent writers don't lose each other's changes.
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Check if the host already exists in the loadMap.
-	if _, ok := c.loadMap.Load(host); ok {
-		return nil // Host already exists, no further action needed.
+	// Check if the host already exists in the ring.
+	r := c.current()
+	if _, ok := r.lookup(host); ok {
+		return fmt.Errorf("%w: %s", ErrHostExists, host)
 	}
 
-	// Add the new host with an initial load of 0.
-	c.loadMap.Store(host, &Host{Name: host, Load: 0})
-	c.hostList = append(c.hostList, host)
-
-	// Add virtual nodes for the host based on the replication factor.
-	for i := 0; i < c.config.ReplicationFactor; i++ {
-		// Generate a hash value for the virtual node.
-		h, err := c.Hash(fmt.Sprintf("%s%d", host, i))
-		if err != nil {
-			log.Fatal("key hashing failed", err) // Log fatal error and exit if hashing fails.
-		}
-		// Store the virtual node hash and map it to the host.
-		c.hosts.Store(h, host)
-		// Append the virtual node hash to the sorted set.
-		c.sortedSet = append(c.sortedSet, h)
+	// Build a new ring with the host and its virtual nodes, then publish it.
+	next, err := r.withHost(&Host{
+		Name:   host,
+		Load:   0,
+		Region: opts.Region,
+		Zone:   opts.Zone,
+		Rack:   opts.Rack,
+		Tokens: copyTokens(opts.Tokens),
+	}, weight)
+	if err != nil {
+		return err
 	}
+	c.ring.Store(next)
 
-	// Sort the hash values in the sorted set.
-	// This allows efficient key lookups using binary search.
-	sort.Slice(c.sortedSet, func(i, j int) bool { return c.sortedSet[i] < c.sortedSet[j] })
+	// Notify subscribers about the new host.
+	c.publish(RingEvent{Type: EventHostAdded, Host: host, Version: c.bumpVersion(), Weight: weight})
 
 	// Return nil to indicate the host was added successfully.
 	return nil
 }
 
+// UpdateWeight changes the weight of an existing host in place.
+// Virtual nodes are only appended or trimmed at the end of the host's vnode sequence,
+// so only the keys owned by the added or removed virtual nodes move.
+func (c *ConsistentHashing) UpdateWeight(ctx context.Context, host string, weight int) error {
+	if weight <= 0 {
+		return ErrInvalidWeight
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.current()
+	i, ok := r.lookup(host)
+	if !ok {
+		return ErrHostNotFound
+	}
+
+	// Grow or shrink the tail of the host's virtual node sequence.
+	next, err := r.withWeight(i, weight)
+	if err != nil {
+		return err
+	}
+	c.ring.Store(next)
+
+	// Notify subscribers about the new weight.
+	c.publish(RingEvent{Type: EventWeightChanged, Host: host, Version: c.bumpVersion(), Weight: weight})
+
+	return nil
+}
+
 // Get retrieves the host that should handle the given key in the consistent hashing ring.
 // It returns the host name and nil error if successful. If no hosts are added, it returns ErrNoHost.
 // If there's an error generating the hash value or searching for it, it returns an appropriate error.
-// If the host associated with the hash value is not found, it returns ErrHostNotFound.
+// Get never blocks: it reads the current ring with a single atomic load, and it does not allocate
+// while the owner of the key is healthy. Unhealthy hosts are skipped for the next healthy host
+// clockwise, unless every host is unhealthy, see SetHealthy.
 func (c *ConsistentHashing) Get(ctx context.Context, key string) (string, error) {
-	// Acquire a read lock to ensure thread safety during read operations.
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	// Return error if no hosts are added
-	if len(c.hostList) == 0 {
-		return "", ErrNoHost
-	}
+	// Load the current ring, it is never modified after it has been published.
+	r := c.current()
 
 	// Generate hash value for the given key using the configured hash function.
-	h, err := c.Hash(key)
+	h, err := r.hash(key)
 	if err != nil {
 		return "", err
 	}
 
-	// Find the closest index in the sorted set for the generated hash value.
-	index, err := c.Search(h)
+	return c.get(r, h)
+}
+
+// GetBytes is like Get for a key given as a byte slice, which is hashed without copying it.
+func (c *ConsistentHashing) GetBytes(ctx context.Context, key []byte) (string, error) {
+	r := c.current()
+	h, err := r.hashBytes(key)
 	if err != nil {
 		return "", err
 	}
+	return c.get(r, h)
+}
 
-	// Retrieve the host associated with the hash value from the hosts map.
-	if host, ok := c.hosts.Load(c.sortedSet[index]); ok {
-		return host.(string), nil
-	}
-
-	// Return an error if the host associated with the hash value is not found.
-	return "", ErrHostNotFound
+// GetHash is like Get for callers that already hashed the key with the configured hash function.
+func (c *ConsistentHashing) GetHash(ctx context.Context, h uint64) (string, error) {
+	return c.get(c.current(), h)
 }
 
-// GetLeast retrieves the host that should handle the given key in the consistent hashing ring
-// with the least current load. It returns the host name and nil error if successful.
+// GetLeast retrieves the host that should handle the given key in the consistent hashing ring,
+// taking the current host loads into account according to the configured Strategy.
+// With StrategyBoundedLoad it returns the first host clockwise from the key whose load is acceptable,
+// so keys only spill over to neighbouring hosts when their owner is saturated.
+// With StrategyLeastLoaded it returns the host with the least load relative to its weight.
+// It returns the host name and nil error if successful.
 // If no hosts are added, it returns ErrNoHost. If there's an error generating the hash value
-// or searching for it, it returns an appropriate error. If no host with acceptable load is found,
-// it falls back to returning the initially found host. If no suitable host is found at all,
-// it returns ErrHostNotFound.
+// or searching for it, it returns an appropriate error. Unhealthy hosts never have acceptable load.
+// If no host with acceptable load is found, it falls back to returning the host Get would return.
 // Bounded Loads: Research Paper: https://research.googleblog.com/2017/04/consistent-hashing-with-bounded-loads.html
 func (c *ConsistentHashing) GetLeast(ctx context.Context, key string) (string, error) {
-	// Acquire a read lock to ensure thread safety during read operations.
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	// Return error if no hosts are added
-	if len(c.hostList) == 0 {
-		return "", ErrNoHost
-	}
+	// Load the current ring, it is never modified after it has been published.
+	r := c.current()
 
 	// Generate hash value for the given key using the configured hash function.
-	h, err := c.Hash(key)
+	h, err := r.hash(key)
 	if err != nil {
 		return "", err
 	}
 
-	// Find the closest index in the sorted set for the generated hash value.
-	index, err := c.Search(h)
+	return c.getLeast(r, h)
+}
+
+// GetLeastBytes is like GetLeast for a key given as a byte slice, which is hashed without copying it.
+func (c *ConsistentHashing) GetLeastBytes(ctx context.Context, key []byte) (string, error) {
+	r := c.current()
+	h, err := r.hashBytes(key)
 	if err != nil {
 		return "", err
 	}
+	return c.getLeast(r, h)
+}
+
+// GetN retrieves the first n distinct hosts clockwise from the given key in the consistent
+// hashing ring, in preference order. The first host is the one Get would return.
+// Unhealthy hosts come after all healthy hosts, in clockwise order.
+// If n is negative, it returns ErrInvalidReplicaCount. If no hosts are added, it returns
+// ErrNoHost. If n exceeds the number of hosts, it returns ErrInsufficientHosts.
+func (c *ConsistentHashing) GetN(ctx context.Context, key string, n int) ([]string, error) {
+	if n < 0 {
+		return nil, ErrInvalidReplicaCount
+	}
+
+	// Load the current ring, it is never modified after it has been published.
+	r := c.current()
+
+	// Return error if no hosts are added or not enough hosts to satisfy n
+	if len(r.hosts) == 0 {
+		return nil, ErrNoHost
+	}
+	if n > len(r.hosts) {
+		return nil, ErrInsufficientHosts
+	}
+
+	// Generate hash value for the given key using the configured hash function.
+	h, err := r.hash(key)
+	if err != nil {
+		return nil, err
+	}
 
-	// Initialize variables to track the host with the least load.
-	var leastLoadedHost string
-	var minLoad int64 = math.MaxInt64
-
-	// Iterate through the sorted set to find the host with the least load.
-	for i := 0; i < len(c.sortedSet); i++ {
-		nextIndex := (index + i) % len(c.sortedSet)
-		if host, ok := c.hosts.Load(c.sortedSet[nextIndex]); ok {
-			// Check if the host's load is acceptable.
-			if c.LoadOk(host.(string)) {
-				// Retrieve the load for the host.
-				if h, ok := c.loadMap.Load(host.(string)); ok {
-					load := h.(*Host).Load
-					// Update the least loaded host if found.
-					if load < minLoad {
-						minLoad = load
-						leastLoadedHost = host.(string)
-					}
-				}
-			}
+	// Collect distinct healthy hosts clockwise until n hosts are found, remembering the unhealthy ones.
+	replicas := make([]string, 0, n)
+	var unhealthy []string
+	r.walkDistinct(r.search(h), func(i int32) bool {
+		if len(replicas) == n {
+			return false
 		}
+		if !r.hosts[i].healthy() {
+			unhealthy = append(unhealthy, r.hosts[i].Name)
+			return true
+		}
+		replicas = append(replicas, r.hosts[i].Name)
+		return true
+	})
+
+	// Fall back to unhealthy hosts if not enough hosts are healthy.
+	for _, host := range unhealthy {
+		if len(replicas) == n {
+			break
+		}
+		replicas = append(replicas, host)
+	}
+
+	c.observeLookup(LookupGetN, false)
+	return replicas, nil
+}
+
+// GetLeastN retrieves n distinct hosts for the given key, applying the bounded-load check
+// to each replica. Hosts are taken clockwise from the key, skipping hosts whose load is not
+// acceptable. With StrategyLeastLoaded the acceptable hosts are ordered by their load relative
+// to their weight instead. If fewer than n hosts pass the load check, the skipped hosts are
+// appended in clockwise order so that n hosts are always returned, healthy hosts before
+// unhealthy ones.
+// If n is negative, it returns ErrInvalidReplicaCount. If no hosts are added, it returns
+// ErrNoHost. If n exceeds the number of hosts, it returns ErrInsufficientHosts.
+func (c *ConsistentHashing) GetLeastN(ctx context.Context, key string, n int) ([]string, error) {
+	if n < 0 {
+		return nil, ErrInvalidReplicaCount
+	}
+
+	// Load the current ring, it is never modified after it has been published.
+	r := c.current()
+
+	// Return error if no hosts are added or not enough hosts to satisfy n
+	if len(r.hosts) == 0 {
+		return nil, ErrNoHost
 	}
+	if n > len(r.hosts) {
+		return nil, ErrInsufficientHosts
+	}
+
+	// Generate hash value for the given key using the configured hash function.
+	h, err := r.hash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect hosts with acceptable load clockwise, remembering the overloaded and unhealthy ones.
+	// The least loaded strategy has to see every host before it can order them.
+	leastLoaded := r.config.Strategy == StrategyLeastLoaded
+	acceptable := make([]int32, 0, n)
+	var overloaded, unhealthy []int32
+	r.walkDistinct(r.search(h), func(i int32) bool {
+		if len(acceptable) == n && !leastLoaded {
+			return false
+		}
+		switch {
+		case !r.hosts[i].healthy():
+			unhealthy = append(unhealthy, i)
+		case c.loadOk(r, i):
+			acceptable = append(acceptable, i)
+		default:
+			overloaded = append(overloaded, i)
+		}
+		return true
+	})
 
-	// If no suitable host with acceptable load is found, return the initially found host.
-	if leastLoadedHost == "" {
-		if host, ok := c.hosts.Load(c.sortedSet[index]); ok {
-			return host.(string), nil
+	// Order the acceptable hosts by relative load, keeping clockwise order between equals.
+	if leastLoaded {
+		loads := make(map[int32]float64, len(acceptable))
+		for _, i := range acceptable {
+			loads[i] = c.relativeLoad(r, i)
+		}
+		sort.SliceStable(acceptable, func(i, j int) bool { return loads[acceptable[i]] < loads[acceptable[j]] })
+		if len(acceptable) > n {
+			acceptable = acceptable[:n]
 		}
 	}
 
-	// Return an error if no suitable host is found.
-	if leastLoadedHost == "" {
-		return "", ErrHostNotFound
+	// Fall back to overloaded hosts if not enough hosts have acceptable load, then to unhealthy hosts.
+	replicas := make([]string, 0, n)
+	order := append(append(acceptable, overloaded...), unhealthy...)
+	for _, i := range order {
+		if len(replicas) == n {
+			break
+		}
+		replicas = append(replicas, r.hosts[i].Name)
 	}
 
-	return leastLoadedHost, nil
+	c.observeLookup(LookupGetLeastN, n > 0 && c.spilled(r, r.owners[r.search(h)], order[0]))
+	return replicas, nil
 }
 
 // IncreaseLoad increments the load for a specific host.
 func (c *ConsistentHashing) IncreaseLoad(ctx context.Context, host string) error {
-	// Check if the host exists in the loadMap.
-	if h, ok := c.loadMap.Load(host); ok {
-		// Retrieve the host data from the loaded value.
-		hostData := h.(*Host)
-
-		// Atomically increment the load for the host by 1.
-		atomic.AddInt64(&hostData.Load, 1)
-
-		// Atomically increment the total load across all hosts by 1.
-		atomic.AddInt64(&c.totalLoad, 1)
+	// Check if the host exists in the current ring.
+	hostData := c.host(host)
+	if hostData == nil {
+		return ErrHostNotFound
+	}
 
-		// Return nil to indicate successful load increment.
-		return nil
+	// Atomically increment the load of the host and the total load by 1.
+	load, err := c.addLoad(hostData, 1)
+	if err != nil {
+		return err
 	}
 
-	// Return an error indicating the host was not found.
-	return ErrHostNotFound
+	// Notify subscribers about the new load.
+	c.publishLoad(host, load)
+	return nil
 }
 
 // DecreaseLoad decreases the Load for a specific host.
+// It returns ErrNegativeLoad and leaves the load unchanged if the host has no load.
 func (c *ConsistentHashing) DecreaseLoad(ctx context.Context, host string) error {
-	// Check if the host exists in the loadMap.
-	if h, ok := c.loadMap.Load(host); ok {
-		// Retrieve the host data from the loaded value.
-		hostData := h.(*Host)
-
-		// Atomically decrement the Load for the host by 1.
-		atomic.AddInt64(&hostData.Load, -1)
-
-		// Atomically decrement the total load across all hosts by 1.
-		atomic.AddInt64(&c.totalLoad, -1)
+	// Check if the host exists in the current ring.
+	hostData := c.host(host)
+	if hostData == nil {
+		return ErrHostNotFound
+	}
 
-		// Return nil to indicate successful load decrement.
-		return nil
+	// Atomically decrement the load of the host and the total load by 1.
+	load, err := c.addLoad(hostData, -1)
+	if err != nil {
+		return err
 	}
 
-	// Return an error indicating the host was not found.
-	return ErrHostNotFound
+	// Notify subscribers about the new load.
+	c.publishLoad(host, load)
+	return nil
 }
 
-// UpdateLoad updates the load for a specific host
+// UpdateLoad updates the load for a specific host.
+// It returns ErrNegativeLoad and leaves the load unchanged if load is negative.
 func (c *ConsistentHashing) UpdateLoad(ctx context.Context, host string, load int64) error {
-	// Check if the host exists in the load map
-	if h, ok := c.loadMap.Load(host); ok {
-		// Type assert the retrieved value to *Host
-		hostData := h.(*Host)
-
-		// Update the total load atomically
-		atomic.AddInt64(&c.totalLoad, -hostData.Load+load)
+	if load < 0 {
+		return ErrNegativeLoad
+	}
 
-		// Store the new load value for the host atomically
-		atomic.StoreInt64(&hostData.Load, load)
+	// Check if the host exists in the current ring
+	hostData := c.host(host)
+	if hostData == nil {
+		return ErrHostNotFound
+	}
 
-		// Successfully updated the load, return nil error
-		return nil
+	// Atomically replace the load of the host and adjust the total load by the difference.
+	if err := c.setLoad(hostData, load); err != nil {
+		return err
 	}
 
-	// If the host is not found, return an error
-	return ErrHostNotFound
+	// Notify subscribers about the new load.
+	c.publishLoad(host, load)
+	return nil
 }
 
 // Remove removes a host from the hash ring
 func (c *ConsistentHashing) Remove(ctx context.Context, host string) error {
-	// Acquire the mutex lock to ensure thread-safety
+	// Acquire the writer lock to ensure thread-safety
 	c.mu.Lock()
 	// Ensure the mutex is unlocked at the end of the function
 	defer c.mu.Unlock()
 
-	// Check if the host exists in the load map
-	if _, ok := c.loadMap.Load(host); !ok {
+	// Check if the host exists in the current ring
+	r := c.current()
+	i, ok := r.lookup(host)
+	if !ok {
 		// If the host is not found, return an error
 		return ErrHostNotFound
 	}
 
-	// Remove the virtual nodes associated with the host
-	for i := 0; i < c.config.ReplicationFactor; i++ {
-		// Generate a hash for the virtual node
-		h, err := c.Hash(fmt.Sprintf("%s%d", host, i))
-		if err != nil {
-			// Log an error and exit if hashing fails
-			log.Fatal("key hashing failed", err)
-		}
-		// Delete the virtual node from the hosts map
-		c.hosts.Delete(h)
-		// Remove the virtual node from the sorted set
-		c.removeFromSortedSet(h)
-	}
-	// Delete the host from the load map
-	c.loadMap.Delete(host)
-
-	// Remove the host from the host list
-	for i, h := range c.hostList {
-		if h == host {
-			// Remove the host from the list by creating a new slice without the host
-			c.hostList = append(c.hostList[:i], c.hostList[i+1:]...)
-			break
-		}
+	// Build a new ring without the host and its virtual nodes, then publish it
+	next, err := r.withoutHost(i)
+	if err != nil {
+		return err
 	}
+	c.ring.Store(next)
+
+	// Take the host's load out of the total load
+	c.retire(r.hosts[i])
+
+	// Notify subscribers about the removed host
+	c.publish(RingEvent{Type: EventHostRemoved, Host: host, Version: c.bumpVersion(), Weight: r.weights[i]})
+
 	// Return nil indicating successful removal
 	return nil
 }
@@ -309,101 +517,303 @@ func (c *ConsistentHashing) Remove(ctx context.Context, host string) error {
 // --------------------------------- Helper Functions ---------------------------------
 
 // hash generates a 64-bit hash value for a given key using the configured hash function.
-// It returns the computed hash value and an error, if any occurred during the hashing process.
+// It returns the computed hash value and an error wrapping ErrHashFailed, if any occurred
+// during the hashing process.
 func (c *ConsistentHashing) Hash(key string) (uint64, error) {
-	// Create a new hash object using the configured hash function.
-	h := c.config.HashFunction()
-
-	// Write the key to the hash object. If an error occurs, panic.
-	if _, err := h.Write([]byte(key)); err != nil {
-		panic(err)
-	}
-
-	// Compute and return the hash value as a 64-bit unsigned integer.
-	return uint64(h.Sum64()), nil
+	return c.current().hash(key)
 }
 
-// Search finds the closest index in the sorted set where the given hash key should be placed.
-// It uses binary search to efficiently locate the index.
-// For example, if c.sortedSet = [10, 20, 30, 40, 50] and key = 25,
+// Search finds the closest index in the sorted set of virtual nodes where the given hash key
+// should be placed. It uses binary search to efficiently locate the index.
+// For example, if the virtual nodes are [10, 20, 30, 40, 50] and key = 25,
 // sort.Search determines that key should be inserted after 20 and before 30, returning index 2.
-// The modulo operation (index % len(c.sortedSet)) ensures correct placement within the ring structure
+// The index wraps around to 0 past the last virtual node to respect the circular ring structure.
+// It returns ErrNoHost if no hosts are added. The index is only meaningful until the next
+// membership change.
 func (c *ConsistentHashing) Search(key uint64) (int, error) {
-	// Perform a binary search on the sorted set to find the index where key should be inserted.
-	index := sort.Search(len(c.sortedSet), func(i int) bool {
-		return c.sortedSet[i] >= key
-	})
-
-	// Wrap around the index using modulo operation to ensure it stays within bounds.
-	// This is necessary for consistent hashing to handle the circular nature of the ring.
-	index = index % len(c.sortedSet)
-
-	// Return the calculated index where the key should be placed.
-	return index, nil
+	r := c.current()
+	if len(r.points) == 0 {
+		return 0, ErrNoHost
+	}
+	return r.search(key), nil
 }
 
 // LoadOk checks if the host's current load is below the maximum allowed load.
 // It returns true if the host's load is acceptable, otherwise false.
 func (c *ConsistentHashing) LoadOk(host string) bool {
-	// Retrieve the host's load data from the loadMap.
-	if h, ok := c.loadMap.Load(host); ok {
-		hostData := h.(*Host)
-		// Compare the host's current load with the maximum allowed load.
-		return hostData.Load < c.MaxLoad()
+	r := c.current()
+	if i, ok := r.lookup(host); ok {
+		return c.loadOk(r, i)
 	}
 	// Return false if host data is not found.
 	return false
 }
 
-// MaxLoad calculates and returns the maximum allowed load per host based on the current
-// total load across all hosts and the configured load factor.
+// MaxLoad calculates and returns the maximum allowed load for a host of weight 1 based on
+// the current total load across all hosts and the configured load factor.
+// When every host has weight 1 this is the maximum allowed load per host.
 func (c *ConsistentHashing) MaxLoad() int64 {
-	// Retrieve the current total load across all hosts.
-	totalLoad := atomic.LoadInt64(&c.totalLoad)
+	return c.maxLoadFor(c.current(), 1)
+}
 
-	// Ensure totalLoad is at least 1 to avoid division by zero.
-	if totalLoad == 0 {
-		totalLoad = 1
+// HostMaxLoad returns the maximum allowed load for the given host, which is proportional
+// to the host's weight. It returns 0 if the host is not found.
+func (c *ConsistentHashing) HostMaxLoad(host string) int64 {
+	r := c.current()
+	if i, ok := r.lookup(host); ok {
+		return c.maxLoadFor(r, r.weights[i])
+	}
+	return 0
+}
+
+// GetLoads returns the current load for all hosts
+func (c *ConsistentHashing) GetLoads() map[string]int64 {
+	r := c.current()
+	loads := make(map[string]int64, len(r.hosts))
+	for _, h := range r.hosts {
+		loads[h.Name] = loadOf(h)
+	}
+	return loads
+}
+
+// getLeast returns the host with acceptable load for the hash value h according to the
+// configured Strategy, falling back to the host get would return if every host is saturated.
+func (c *ConsistentHashing) getLeast(r *ring, h uint64) (string, error) {
+	// Return error if no hosts are added
+	if len(r.points) == 0 {
+		return "", ErrNoHost
 	}
 
-	// Calculate the average load per host.
-	avgLoadPerNode := float64(totalLoad) / float64(len(c.hostList))
+	return r.hosts[c.leastIndex(r, h)].Name, nil
+}
+
+// leastIndex returns the index of the host with acceptable load for the hash value h according
+// to the configured Strategy, falling back to the host get would return if every host is saturated.
+// The ring must not be empty.
+func (c *ConsistentHashing) leastIndex(r *ring, h uint64) int32 {
+	// Find the closest virtual node for the generated hash value.
+	index := r.search(h)
+
+	// Pick a host with acceptable load according to the configured strategy.
+	var host int32
+	switch r.config.Strategy {
+	case StrategyLeastLoaded:
+		host = c.leastLoaded(r, index)
+	default:
+		host = c.boundedLoad(r, index)
+	}
 
-	// Ensure avgLoadPerNode is at least 1 to avoid division by zero.
-	if avgLoadPerNode == 0 {
-		avgLoadPerNode = 1
+	// If no suitable host with acceptable load is found, return the first healthy host.
+	if host < 0 {
+		c.observeLookup(LookupGetLeast, false)
+		return c.healthyIndex(r, index)
 	}
 
-	// Calculate and return the maximum allowed load per host based on the load factor.
-	return int64(math.Ceil(avgLoadPerNode * c.config.LoadFactor))
+	c.observeLookup(LookupGetLeast, c.spilled(r, r.owners[index], host))
+	return host
 }
 
-// GetLoads returns the current load for all hosts
-func (c *ConsistentHashing) GetLoads() map[string]int64 {
-	loads := make(map[string]int64)
-	c.loadMap.Range(func(key, value interface{}) bool {
-		loads[key.(string)] = value.(*Host).Load
+// get returns the name of the first healthy host clockwise from the hash value h and reports the lookup.
+func (c *ConsistentHashing) get(r *ring, h uint64) (string, error) {
+	// Return error if no hosts are added
+	if len(r.points) == 0 {
+		return "", ErrNoHost
+	}
+
+	host := r.hosts[c.healthyIndex(r, r.search(h))].Name
+	c.observeLookup(LookupGet, false)
+	return host, nil
+}
+
+// healthyIndex returns the index of the owner of the virtual node at index if it is healthy,
+// otherwise of the first healthy host clockwise from it. If every host is unhealthy it returns
+// the owner, so lookups keep working when health checks fail everywhere.
+func (c *ConsistentHashing) healthyIndex(r *ring, index int) int32 {
+	// Most of the time the owner itself is healthy, which needs no walk.
+	owner := r.owners[index]
+	if r.hosts[owner].healthy() {
+		return owner
+	}
+
+	found := owner
+	r.walkDistinct(index, func(i int32) bool {
+		if r.hosts[i].healthy() {
+			found = i
+			return false
+		}
 		return true
 	})
-	return loads
+	return found
+}
+
+// host returns the named host of the current ring, or nil if it is not in the ring.
+func (c *ConsistentHashing) host(name string) *Host {
+	r := c.current()
+	if i, ok := r.lookup(name); ok {
+		return r.hosts[i]
+	}
+	return nil
 }
 
-func (c *ConsistentHashing) removeFromSortedSet(val uint64) {
-	// Use binary search to find the index of the value
-	index := sort.Search(len(c.sortedSet), func(i int) bool {
-		return c.sortedSet[i] >= val
+// loadOk checks if the host at index i of the ring is healthy and its load is below its maximum
+// allowed load.
+func (c *ConsistentHashing) loadOk(r *ring, i int32) bool {
+	// Compare the host's current load with the maximum allowed load for its weight.
+	// Hosts removed since the ring was loaded and unhealthy hosts are never acceptable.
+	load := atomic.LoadInt64(&r.hosts[i].Load)
+	return load != removedLoad && r.hosts[i].healthy() && load < c.maxLoadFor(r, r.weights[i])
+}
+
+// spilled reports whether a lookup that returned host instead of owner spilled over because the
+// owner is saturated. Skipping an unhealthy or removed owner is not a spill.
+func (c *ConsistentHashing) spilled(r *ring, owner, host int32) bool {
+	load := atomic.LoadInt64(&r.hosts[owner].Load)
+	return host != owner && load != removedLoad && r.hosts[owner].healthy() && load >= c.maxLoadFor(r, r.weights[owner])
+}
+
+// boundedLoad returns the index of the first host clockwise from index whose load is acceptable,
+// or -1 if every host is saturated.
+func (c *ConsistentHashing) boundedLoad(r *ring, index int) int32 {
+	// Most of the time the owner itself is acceptable, which needs no walk.
+	if owner := r.owners[index]; c.loadOk(r, owner) {
+		return owner
+	}
+
+	found := int32(-1)
+	r.walkDistinct(index, func(i int32) bool {
+		if c.loadOk(r, i) {
+			found = i
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// leastLoaded scans the whole ring starting at index and returns the index of the host with
+// acceptable load that has the least load relative to its weight, or -1 if every host is saturated.
+func (c *ConsistentHashing) leastLoaded(r *ring, index int) int32 {
+	// Initialize variables to track the host with the least load relative to its weight.
+	leastLoadedHost := int32(-1)
+	var minLoad = math.MaxFloat64
+
+	// Iterate through the ring to find the host with the least load.
+	r.walkDistinct(index, func(i int32) bool {
+		// Check if the host's load is acceptable.
+		if !c.loadOk(r, i) {
+			return true
+		}
+		// Update the least loaded host if found.
+		if load := c.relativeLoad(r, i); load < minLoad {
+			minLoad = load
+			leastLoadedHost = i
+		}
+		return true
 	})
 
-	// If the value is found, remove it
-	if index < len(c.sortedSet) && c.sortedSet[index] == val {
-		// Remove the element by slicing
-		c.sortedSet = append(c.sortedSet[:index], c.sortedSet[index+1:]...)
+	return leastLoadedHost
+}
+
+// relativeLoad returns the load of the host at index i of the ring divided by its weight.
+// Hosts removed since the ring was loaded have an infinite relative load.
+func (c *ConsistentHashing) relativeLoad(r *ring, i int32) float64 {
+	load := atomic.LoadInt64(&r.hosts[i].Load)
+	if load == removedLoad {
+		return math.Inf(1)
 	}
+	return float64(load) / float64(r.weights[i])
+}
+
+// withDefaults fills in the default values for the unset config parameters.
+// It returns ErrUnknownHash if HashName does not name a registered hash function, an error
+// wrapping ErrInvalidSeed if HashSeed is not a valid seed for it and ErrUnknownVNodeScheme if
+// VNodeScheme does not name a registered vnode naming scheme.
+func withDefaults(cfg Config) (Config, error) {
+	if cfg.ReplicationFactor <= 0 {
+		cfg.ReplicationFactor = 10
+	}
+
+	if cfg.LoadFactor <= 1 {
+		cfg.LoadFactor = 1.25
+	}
+
+	// Resolve the hash function by name, or record the name of a registered hash function.
+	// The seed is copied so that later changes by the caller don't affect the ring.
+	cfg.HashSeed = append([]byte(nil), cfg.HashSeed...)
+	switch {
+	case cfg.HashFunction == nil && cfg.HashName == "" && len(cfg.HashSeed) > 0:
+		return cfg, fmt.Errorf("%w: a seed needs a keyed hash function named by HashName", ErrInvalidSeed)
+	case cfg.HashFunction == nil && cfg.HashName == "":
+		cfg.HashFunction, cfg.HashName = fnv.New64a, "fnv64a"
+	case cfg.HashFunction == nil:
+		fn, err := KeyedHashByName(cfg.HashName, cfg.HashSeed)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.HashFunction = fn
+	case cfg.HashName == "":
+		cfg.HashName = hashNameOf(cfg.HashFunction)
+	}
+
+	// Resolve the vnode naming scheme the same way as the hash function.
+	switch {
+	case cfg.VNodeKey == nil && cfg.VNodeScheme == "":
+		cfg.VNodeKey, cfg.VNodeScheme = LengthPrefixedVNodeKey, DefaultVNodeScheme
+	case cfg.VNodeKey == nil:
+		key, err := VNodeSchemeByName(cfg.VNodeScheme)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.VNodeKey = key
+	case cfg.VNodeScheme == "":
+		cfg.VNodeScheme = vnodeSchemeOf(cfg.VNodeKey)
+	}
+
+	if cfg.MaglevTableSize <= 0 {
+		cfg.MaglevTableSize = 65537
+	}
+
+	if cfg.MultiProbeCount <= 0 {
+		cfg.MultiProbeCount = 21
+	}
+
+	return cfg, nil
+}
+
+// maxLoadFor calculates the maximum allowed load for a host of the given weight.
+// The average load per unit of healthy weight is scaled by the host's weight and the load factor.
+func (c *ConsistentHashing) maxLoadFor(r *ring, weight int) int64 {
+	// Retrieve the current total load across all hosts.
+	totalLoad := atomic.LoadInt64(&c.totalLoad)
+
+	// Ensure totalLoad is at least 1 to avoid division by zero.
+	if totalLoad == 0 {
+		totalLoad = 1
+	}
+
+	// Spread the load over the healthy hosts only, unless every host is unhealthy.
+	// Ensure totalWeight is at least 1 to avoid division by zero.
+	totalWeight := r.healthy
+	if totalWeight == 0 {
+		totalWeight = r.weight
+	}
+	if totalWeight == 0 {
+		totalWeight = 1
+	}
+
+	// Calculate the average load per unit of weight.
+	avgLoadPerWeight := float64(totalLoad) / float64(totalWeight)
+
+	// Calculate and return the maximum allowed load for the weight based on the load factor.
+	return int64(math.Ceil(avgLoadPerWeight * float64(weight) * r.config.LoadFactor))
 }
 
 // Hosts returns the list of current hosts
 func (c *ConsistentHashing) Hosts() []string {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return append([]string(nil), c.hostList...)
+	r := c.current()
+	hosts := make([]string, 0, len(r.hosts))
+	for _, h := range r.hosts {
+		hosts = append(hosts, h.Name)
+	}
+	return hosts
 }
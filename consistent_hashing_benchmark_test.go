@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"hash/fnv"
 	"testing"
+
+	"github.com/spaolacci/murmur3"
 )
 
 func BenchmarkAdd(b *testing.B) {
@@ -35,6 +37,72 @@ func BenchmarkGet(b *testing.B) {
 	}
 }
 
+// newLookupBenchmark returns a ring of 100 hosts and pre-built keys, so lookup benchmarks
+// only measure the lookup itself.
+func newLookupBenchmark() (*ConsistentHashing, []string) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 100, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	for i := 0; i < 100; i++ {
+		_ = ch.Add(context.Background(), fmt.Sprintf("host-%d", i))
+	}
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	return ch, keys
+}
+
+func BenchmarkGetNoAlloc(b *testing.B) {
+	ch, keys := newLookupBenchmark()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = ch.Get(ctx, keys[i%len(keys)])
+	}
+}
+
+func BenchmarkGetBytes(b *testing.B) {
+	ch, keys := newLookupBenchmark()
+	ctx := context.Background()
+	byteKeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		byteKeys[i] = []byte(key)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = ch.GetBytes(ctx, byteKeys[i%len(byteKeys)])
+	}
+}
+
+func BenchmarkGetHash(b *testing.B) {
+	ch, _ := newLookupBenchmark()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = ch.GetHash(ctx, uint64(i)*0x9e3779b97f4a7c15)
+	}
+}
+
+func BenchmarkGetMurmur3(b *testing.B) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 100, LoadFactor: 1.25, HashFunction: murmur3.New64})
+	_, keys := newLookupBenchmark()
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		_ = ch.Add(ctx, fmt.Sprintf("host-%d", i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = ch.Get(ctx, keys[i%len(keys)])
+	}
+}
+
 func BenchmarkGetLeast(b *testing.B) {
 	ch, _ := NewWithConfig(Config{ReplicationFactor: 100, LoadFactor: 1.25, HashFunction: fnv.New64a})
 	ctx := context.Background()
@@ -124,3 +192,24 @@ func BenchmarkParallelOperations(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkBalancers(b *testing.B) {
+	for name, algorithm := range algorithms {
+		b.Run(name, func(b *testing.B) {
+			balancer, _ := NewBalancer(Config{Algorithm: algorithm, ReplicationFactor: 100, HashFunction: fnv.New64a})
+			ctx := context.Background()
+
+			// Add some hosts
+			for i := 0; i < 100; i++ {
+				host := fmt.Sprintf("host-%d", i)
+				_ = balancer.Add(ctx, host)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := fmt.Sprintf("key-%d", i)
+				_, _ = balancer.Get(ctx, key)
+			}
+		})
+	}
+}
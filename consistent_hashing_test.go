@@ -2,7 +2,9 @@ package consistent_hashing
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hash"
 	"hash/fnv"
 	"math"
 	"sync"
@@ -19,11 +21,43 @@ func TestNewWithConfig(t *testing.T) {
 	if err != nil {
 		t.Errorf("NewWithConfig failed: %v", err)
 	}
-	if ch.config.ReplicationFactor != 10 {
-		t.Errorf("Expected ReplicationFactor 10, got %d", ch.config.ReplicationFactor)
+	if ch.ring.Load().config.ReplicationFactor != 10 {
+		t.Errorf("Expected ReplicationFactor 10, got %d", ch.ring.Load().config.ReplicationFactor)
 	}
-	if ch.config.LoadFactor != 1.25 {
-		t.Errorf("Expected LoadFactor 1.25, got %f", ch.config.LoadFactor)
+	if ch.ring.Load().config.LoadFactor != 1.25 {
+		t.Errorf("Expected LoadFactor 1.25, got %f", ch.ring.Load().config.LoadFactor)
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	ctx := context.Background()
+
+	// A zero value is an empty ring with the default config.
+	var ch ConsistentHashing
+	if _, err := ch.Get(ctx, "key1"); err != ErrNoHost {
+		t.Errorf("Expected ErrNoHost, got %v", err)
+	}
+	if _, err := ch.GetLeast(ctx, "key1"); err != ErrNoHost {
+		t.Errorf("Expected ErrNoHost, got %v", err)
+	}
+	if _, err := ch.GetN(ctx, "key1", 1); err != ErrNoHost {
+		t.Errorf("Expected ErrNoHost, got %v", err)
+	}
+	if len(ch.Hosts()) != 0 || len(ch.GetLoads()) != 0 || ch.Stats().Hosts != 0 {
+		t.Errorf("Expected no hosts, got %v", ch.Hosts())
+	}
+	if _, err := ch.MarshalJSON(); err != nil {
+		t.Errorf("MarshalJSON failed: %v", err)
+	}
+
+	if err := ch.Add(ctx, "host1"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if host, _ := ch.Get(ctx, "key1"); host != "host1" {
+		t.Errorf("Expected host1, got %s", host)
+	}
+	if cfg := ch.ring.Load().config; cfg.HashName != "fnv64a" || cfg.ReplicationFactor != 10 {
+		t.Errorf("Expected the default config, got %+v", *cfg)
 	}
 }
 
@@ -34,11 +68,11 @@ func TestAdd(t *testing.T) {
 	if err != nil {
 		t.Errorf("Add failed: %v", err)
 	}
-	if len(ch.hostList) != 1 {
-		t.Errorf("Expected 1 host, got %d", len(ch.hostList))
+	if len(ch.Hosts()) != 1 {
+		t.Errorf("Expected 1 host, got %d", len(ch.Hosts()))
 	}
-	if len(ch.sortedSet) != 3 {
-		t.Errorf("Expected 3 virtual nodes, got %d", len(ch.sortedSet))
+	if len(ch.ring.Load().points) != 3 {
+		t.Errorf("Expected 3 virtual nodes, got %d", len(ch.ring.Load().points))
 	}
 }
 
@@ -120,11 +154,11 @@ func TestRemove(t *testing.T) {
 	if err != nil {
 		t.Errorf("Remove failed: %v", err)
 	}
-	if len(ch.hostList) != 1 {
-		t.Errorf("Expected 1 host, got %d", len(ch.hostList))
+	if len(ch.Hosts()) != 1 {
+		t.Errorf("Expected 1 host, got %d", len(ch.Hosts()))
 	}
-	if ch.hostList[0] != "host2" {
-		t.Errorf("Expected host2, got %s", ch.hostList[0])
+	if ch.Hosts()[0] != "host2" {
+		t.Errorf("Expected host2, got %s", ch.Hosts()[0])
 	}
 }
 
@@ -152,7 +186,7 @@ func TestConcurrency(t *testing.T) {
 }
 
 func TestLoadBalancing(t *testing.T) {
-	ch, _ := NewWithConfig(Config{ReplicationFactor: 100, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 100, LoadFactor: 1.25, HashFunction: fnv.New64a, Strategy: StrategyLeastLoaded})
 	ctx := context.Background()
 	hosts := []string{"host1", "host2", "host3", "host4", "host5"}
 
@@ -189,3 +223,284 @@ func TestLoadBalancing(t *testing.T) {
 		}
 	}
 }
+
+func TestAddWithWeight(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	if err := ch.AddWithWeight(ctx, "host1", 0); err != ErrInvalidWeight {
+		t.Errorf("Expected ErrInvalidWeight, got %v", err)
+	}
+	ch.AddWithWeight(ctx, "host1", 1)
+	ch.AddWithWeight(ctx, "host2", 4)
+	if len(ch.ring.Load().points) != 15 {
+		t.Errorf("Expected 15 virtual nodes, got %d", len(ch.ring.Load().points))
+	}
+	ch.Remove(ctx, "host2")
+	if len(ch.ring.Load().points) != 3 {
+		t.Errorf("Expected 3 virtual nodes after removal, got %d", len(ch.ring.Load().points))
+	}
+}
+
+func TestUpdateWeight(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 50, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	for _, host := range []string{"host1", "host2", "host3"} {
+		ch.Add(ctx, host)
+	}
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		before[key], _ = ch.Get(ctx, key)
+	}
+
+	if err := ch.UpdateWeight(ctx, "host1", 2); err != nil {
+		t.Fatalf("UpdateWeight failed: %v", err)
+	}
+	if len(ch.ring.Load().points) != 200 {
+		t.Errorf("Expected 200 virtual nodes, got %d", len(ch.ring.Load().points))
+	}
+	// Growing a host's weight must only move keys onto that host.
+	for key, old := range before {
+		host, _ := ch.Get(ctx, key)
+		if host != old && host != "host1" {
+			t.Errorf("Key %s moved from %s to %s", key, old, host)
+		}
+	}
+
+	// Shrinking back must restore the original placement.
+	ch.UpdateWeight(ctx, "host1", 1)
+	for key, old := range before {
+		if host, _ := ch.Get(ctx, key); host != old {
+			t.Errorf("Key %s expected on %s, got %s", key, old, host)
+		}
+	}
+	if err := ch.UpdateWeight(ctx, "host4", 2); err != ErrHostNotFound {
+		t.Errorf("Expected ErrHostNotFound, got %v", err)
+	}
+}
+
+func TestHostMaxLoad(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	ch.AddWithWeight(ctx, "host1", 1)
+	ch.AddWithWeight(ctx, "host2", 3)
+	ch.UpdateLoad(ctx, "host1", 40)
+	ch.UpdateLoad(ctx, "host2", 40)
+	// 80 load over 4 units of weight, scaled by 1.25
+	if got := ch.HostMaxLoad("host1"); got != 25 {
+		t.Errorf("Expected max load 25 for host1, got %d", got)
+	}
+	if got := ch.HostMaxLoad("host2"); got != 75 {
+		t.Errorf("Expected max load 75 for host2, got %d", got)
+	}
+	if ch.LoadOk("host1") {
+		t.Errorf("Expected host1 to be overloaded")
+	}
+	if !ch.LoadOk("host2") {
+		t.Errorf("Expected host2 to accept more load")
+	}
+}
+
+func TestGetN(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	if _, err := ch.GetN(ctx, "key1", 1); err != ErrNoHost {
+		t.Errorf("Expected ErrNoHost, got %v", err)
+	}
+	for _, host := range []string{"host1", "host2", "host3", "host4"} {
+		ch.Add(ctx, host)
+	}
+	replicas, err := ch.GetN(ctx, "key1", 3)
+	if err != nil {
+		t.Fatalf("GetN failed: %v", err)
+	}
+	if len(replicas) != 3 {
+		t.Fatalf("Expected 3 replicas, got %d", len(replicas))
+	}
+	seen := make(map[string]bool)
+	for _, host := range replicas {
+		if seen[host] {
+			t.Errorf("Duplicate replica %s in %v", host, replicas)
+		}
+		seen[host] = true
+	}
+	if host, _ := ch.Get(ctx, "key1"); replicas[0] != host {
+		t.Errorf("Expected first replica %s, got %s", host, replicas[0])
+	}
+	if _, err := ch.GetN(ctx, "key1", 5); err != ErrInsufficientHosts {
+		t.Errorf("Expected ErrInsufficientHosts, got %v", err)
+	}
+	if _, err := ch.GetN(ctx, "key1", -1); err != ErrInvalidReplicaCount {
+		t.Errorf("Expected ErrInvalidReplicaCount, got %v", err)
+	}
+	if _, err := ch.GetLeastN(ctx, "key1", -1); err != ErrInvalidReplicaCount {
+		t.Errorf("Expected ErrInvalidReplicaCount, got %v", err)
+	}
+}
+
+func TestGetLeastN(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	for _, host := range []string{"host1", "host2", "host3"} {
+		ch.Add(ctx, host)
+	}
+	replicas, _ := ch.GetN(ctx, "key1", 3)
+
+	// Overload the primary replica so it is moved to the end of the preference list.
+	ch.UpdateLoad(ctx, replicas[0], 10)
+	least, err := ch.GetLeastN(ctx, "key1", 3)
+	if err != nil {
+		t.Fatalf("GetLeastN failed: %v", err)
+	}
+	expected := []string{replicas[1], replicas[2], replicas[0]}
+	for i := range expected {
+		if least[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, least)
+			break
+		}
+	}
+}
+
+func TestBoundedLoadStrategy(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 100, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	hosts := []string{"host1", "host2", "host3", "host4", "host5"}
+	for _, host := range hosts {
+		ch.Add(ctx, host)
+	}
+
+	// Without load every key stays on its owner.
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owner, _ := ch.Get(ctx, key)
+		if host, _ := ch.GetLeast(ctx, key); host != owner {
+			t.Errorf("Expected key %s on owner %s, got %s", key, owner, host)
+		}
+	}
+
+	// A saturated owner spills its keys over to the next host clockwise.
+	owner, _ := ch.Get(ctx, "key1")
+	replicas, _ := ch.GetN(ctx, "key1", 2)
+	ch.UpdateLoad(ctx, owner, 10)
+	if host, _ := ch.GetLeast(ctx, "key1"); host != replicas[1] {
+		t.Errorf("Expected spill over to %s, got %s", replicas[1], host)
+	}
+
+	// No host may exceed the bounded load while assigning keys.
+	ch.UpdateLoad(ctx, owner, 0)
+	for i := 0; i < 10000; i++ {
+		host, err := ch.GetLeast(ctx, fmt.Sprintf("key%d", i))
+		if err != nil {
+			t.Fatalf("GetLeast failed: %v", err)
+		}
+		ch.IncreaseLoad(ctx, host)
+	}
+	for host, load := range ch.GetLoads() {
+		if load > ch.HostMaxLoad(host) {
+			t.Errorf("Load %d for %s exceeds max load %d", load, host, ch.HostMaxLoad(host))
+		}
+	}
+}
+
+// constantHash is a hash function that maps every key to the same value.
+type constantHash struct{ hash.Hash64 }
+
+func (constantHash) Sum64() uint64 { return 42 }
+
+// failingHash is a hash function whose writes always fail.
+type failingHash struct{ hash.Hash64 }
+
+var errWrite = errors.New("write failed")
+
+func (failingHash) Write([]byte) (int, error) { return 0, errWrite }
+
+func TestAddErrors(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 1, HashFunction: func() hash.Hash64 { return constantHash{fnv.New64a()} }})
+	ctx := context.Background()
+	if err := ch.Add(ctx, ""); !errors.Is(err, ErrInvalidHost) {
+		t.Errorf("Expected ErrInvalidHost, got %v", err)
+	}
+	if err := ch.Add(ctx, "host1"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := ch.Add(ctx, "host1"); !errors.Is(err, ErrHostExists) {
+		t.Errorf("Expected ErrHostExists, got %v", err)
+	}
+	if err := ch.Add(ctx, "host2"); !errors.Is(err, ErrVNodeCollision) {
+		t.Errorf("Expected ErrVNodeCollision, got %v", err)
+	}
+	// A rejected host leaves the ring unchanged.
+	if len(ch.Hosts()) != 1 || len(ch.ring.Load().points) != 1 {
+		t.Errorf("Expected the ring to be unchanged, got hosts %v and %d vnodes", ch.Hosts(), len(ch.ring.Load().points))
+	}
+	if host, _ := ch.Get(ctx, "key1"); host != "host1" {
+		t.Errorf("Expected host1, got %s", host)
+	}
+}
+
+func TestHashErrors(t *testing.T) {
+	ch, _ := NewWithConfig(Config{HashFunction: func() hash.Hash64 { return failingHash{fnv.New64a()} }})
+	ctx := context.Background()
+	err := ch.Add(ctx, "host1")
+	if !errors.Is(err, ErrHashFailed) || !errors.Is(err, errWrite) {
+		t.Errorf("Expected ErrHashFailed wrapping the write error, got %v", err)
+	}
+	if len(ch.Hosts()) != 0 {
+		t.Errorf("Expected no hosts, got %v", ch.Hosts())
+	}
+}
+
+func TestGetBytesAndHash(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	if _, err := ch.GetBytes(ctx, []byte("key")); err != ErrNoHost {
+		t.Errorf("Expected ErrNoHost, got %v", err)
+	}
+	if _, err := ch.GetHash(ctx, 42); err != ErrNoHost {
+		t.Errorf("Expected ErrNoHost, got %v", err)
+	}
+	ch.AddHosts(ctx, "host1", "host2", "host3")
+
+	// Every entry point agrees with Get for the same key.
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		want, _ := ch.Get(ctx, key)
+		h, _ := ch.Hash(key)
+		byBytes, _ := ch.GetBytes(ctx, []byte(key))
+		byHash, _ := ch.GetHash(ctx, h)
+		if byBytes != want || byHash != want {
+			t.Errorf("Key %s: expected %s, got %s by bytes and %s by hash", key, want, byBytes, byHash)
+		}
+		least, _ := ch.GetLeast(ctx, key)
+		leastBytes, _ := ch.GetLeastBytes(ctx, []byte(key))
+		if leastBytes != least {
+			t.Errorf("Key %s: expected %s, got %s by bytes", key, least, leastBytes)
+		}
+	}
+
+	// The inline FNV-1a matches hash/fnv.
+	h := fnv.New64a()
+	h.Write([]byte("key"))
+	if got, _ := ch.Hash("key"); got != h.Sum64() {
+		t.Errorf("Expected %d, got %d", h.Sum64(), got)
+	}
+}
+
+func TestGetDoesNotAllocate(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.AddHosts(ctx, "host1", "host2", "host3")
+	key := []byte("key")
+
+	lookups := map[string]func(){
+		"Get":      func() { ch.Get(ctx, "key") },
+		"GetBytes": func() { ch.GetBytes(ctx, key) },
+		"GetHash":  func() { ch.GetHash(ctx, 42) },
+		"GetLeast": func() { ch.GetLeast(ctx, "key") },
+	}
+	for name, lookup := range lookups {
+		if allocs := testing.AllocsPerRun(100, lookup); allocs != 0 {
+			t.Errorf("Expected %s not to allocate, got %f allocations", name, allocs)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package consistent_hashing
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// subscriberBuffer is the no of events buffered per subscriber before events are dropped.
+const subscriberBuffer = 64
+
+// EventType is the kind of change a RingEvent describes.
+type EventType int
+
+const (
+	// EventHostAdded is emitted when a host is added to the ring.
+	EventHostAdded EventType = iota + 1
+	// EventHostRemoved is emitted when a host is removed from the ring.
+	EventHostRemoved
+	// EventWeightChanged is emitted when the weight of a host changes.
+	EventWeightChanged
+	// EventLoadChanged is emitted when the load of a host changes.
+	EventLoadChanged
+	// EventBatchApplied is emitted once for a batch of changes applied with Apply.
+	EventBatchApplied
+	// EventHostUnhealthy is emitted when a host is marked unhealthy.
+	EventHostUnhealthy
+	// EventHostHealthy is emitted when an unhealthy host is marked healthy again.
+	EventHostHealthy
+	// EventRingRestored is emitted when the whole ring is replaced by a restored snapshot.
+	EventRingRestored
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventHostAdded:
+		return "HostAdded"
+	case EventHostRemoved:
+		return "HostRemoved"
+	case EventWeightChanged:
+		return "WeightChanged"
+	case EventLoadChanged:
+		return "LoadChanged"
+	case EventBatchApplied:
+		return "BatchApplied"
+	case EventHostUnhealthy:
+		return "HostUnhealthy"
+	case EventHostHealthy:
+		return "HostHealthy"
+	case EventRingRestored:
+		return "RingRestored"
+	default:
+		return "Unknown"
+	}
+}
+
+// RingEvent describes a change of the ring or of a host's load or health.
+type RingEvent struct {
+	Type    EventType // kind of change
+	Host    string    // host the change applies to
+	Version uint64    // ring version after the change, load and health changes do not bump it
+	Weight  int       // weight of the host for membership and weight changes
+	Load    int64     // load of the host for load changes
+	Changes []Change  // changes of the batch for batch events
+}
+
+// subscribers keeps track of the channels returned by Subscribe.
+type subscribers struct {
+	mu    sync.Mutex
+	chans map[chan RingEvent]struct{}
+	count int32 // no of subscribers, read without the lock to skip publishing
+}
+
+// Subscribe returns a channel that receives an event for every membership, weight, load and
+// health change of the ring, and for every restored snapshot. The channel is buffered; events
+// are dropped for subscribers that do not keep up so that slow subscribers never block Add or
+// Remove. The channel is closed and the subscription removed once ctx is cancelled.
+func (c *ConsistentHashing) Subscribe(ctx context.Context) <-chan RingEvent {
+	ch := make(chan RingEvent, subscriberBuffer)
+
+	c.subs.mu.Lock()
+	if c.subs.chans == nil {
+		c.subs.chans = make(map[chan RingEvent]struct{})
+	}
+	c.subs.chans[ch] = struct{}{}
+	atomic.AddInt32(&c.subs.count, 1)
+	c.subs.mu.Unlock()
+
+	// Clean up the subscription once the subscriber is gone.
+	go func() {
+		<-ctx.Done()
+		c.subs.mu.Lock()
+		delete(c.subs.chans, ch)
+		atomic.AddInt32(&c.subs.count, -1)
+		close(ch)
+		c.subs.mu.Unlock()
+	}()
+
+	return ch
+}
+
+// Version returns the current ring version. It increases by one on every membership
+// or weight change, and never decreases when a snapshot is restored.
+func (c *ConsistentHashing) Version() uint64 {
+	return atomic.LoadUint64(&c.version)
+}
+
+// bumpVersion increments the ring version and returns the new version.
+func (c *ConsistentHashing) bumpVersion() uint64 {
+	return atomic.AddUint64(&c.version, 1)
+}
+
+// publish reports the event to the observer and sends it to every subscriber without blocking.
+func (c *ConsistentHashing) publish(ev RingEvent) {
+	if o := c.observer.Load(); o != nil {
+		(*o).ObserveEvent(ev)
+	}
+	if atomic.LoadInt32(&c.subs.count) == 0 {
+		return
+	}
+
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+	for ch := range c.subs.chans {
+		select {
+		case ch <- ev:
+		default: // Subscriber is not keeping up, drop the event.
+		}
+	}
+}
+
+// publishLoad sends a load change event for the host to every subscriber.
+func (c *ConsistentHashing) publishLoad(host string, load int64) {
+	c.publish(RingEvent{Type: EventLoadChanged, Host: host, Version: c.Version(), Load: load})
+}
@@ -0,0 +1,67 @@
+package consistent_hashing
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3})
+	ctx, cancel := context.WithCancel(context.Background())
+	events := ch.Subscribe(ctx)
+
+	ch.Add(ctx, "host1")
+	ch.UpdateWeight(ctx, "host1", 2)
+	ch.IncreaseLoad(ctx, "host1")
+	ch.Remove(ctx, "host1")
+
+	expected := []RingEvent{
+		{Type: EventHostAdded, Host: "host1", Version: 1, Weight: 1},
+		{Type: EventWeightChanged, Host: "host1", Version: 2, Weight: 2},
+		{Type: EventLoadChanged, Host: "host1", Version: 2, Load: 1},
+		{Type: EventHostRemoved, Host: "host1", Version: 3, Weight: 2},
+	}
+	for _, want := range expected {
+		if got := <-events; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+	}
+	if ch.Version() != 3 {
+		t.Errorf("Expected version 3, got %d", ch.Version())
+	}
+
+	// Cancelling the context closes the channel.
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Errorf("Expected closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Channel was not closed after cancel")
+	}
+}
+
+func TestSubscribeSlowSubscriber(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch.Subscribe(ctx)
+
+	// A subscriber that never reads must not block the ring.
+	done := make(chan struct{})
+	go func() {
+		ch.Add(ctx, "host1")
+		for i := 0; i < 10*subscriberBuffer; i++ {
+			ch.IncreaseLoad(ctx, "host1")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Slow subscriber blocked the ring")
+	}
+}
@@ -2,4 +2,8 @@ module github.com/ArchishmanSengupta/consistent-hashing
 
 go 1.20
 
-require github.com/spaolacci/murmur3 v1.1.0
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/dchest/siphash v1.2.3
+	github.com/spaolacci/murmur3 v1.1.0
+)
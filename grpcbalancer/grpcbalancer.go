@@ -0,0 +1,244 @@
+// Package grpcbalancer is a gRPC client-side load balancer that routes RPCs with a consistent
+// hashing ring, so RPCs with the same routing key stick to the same backend. It lives in its own
+// module so that the ring itself does not depend on gRPC.
+//
+// Importing the package registers the balancer under Name with the default ring config:
+//
+//	conn, err := grpc.NewClient(target,
+//		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"consistent_hashing": {}}]}`))
+//	resp, err := client.Get(grpcbalancer.WithRoutingKey(ctx, userID), req)
+//
+// The ring is rebuilt from every resolver update, keyed by backend address. Every RPC counts
+// towards the load of its backend until it finishes, so keys spill over to the next replica once
+// their backend exceeds its bounded load, and backends whose connection is not READY are skipped.
+package grpcbalancer
+
+import (
+	"context"
+	"sync/atomic"
+
+	ch "github.com/ArchishmanSengupta/consistent-hashing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
+)
+
+// Name is the name the balancer is registered under.
+const Name = "consistent_hashing"
+
+// Defaults of the ring config. Backend addresses differ in a few characters only, which FNV,
+// the ring's default hash function, spreads poorly, and clusters are usually small, so the
+// balancer uses xxHash and more virtual nodes per backend than the ring does by default.
+const (
+	DefaultHashName          = "xxhash64"
+	DefaultReplicationFactor = 100
+)
+
+// DefaultMetadataKey is the outgoing metadata key the routing key is read from unless
+// Config.MetadataKey is set.
+const DefaultMetadataKey = "x-routing-key"
+
+func init() {
+	b, _ := NewBuilder(Config{})
+	balancer.Register(b)
+}
+
+// Config configures the balancer built by NewBuilder.
+type Config struct {
+	Name        string    // name to register the balancer under, defaults to Name
+	Ring        ch.Config // config of the ring built for every client connection, see DefaultHashName and DefaultReplicationFactor
+	MetadataKey string    // outgoing metadata key of the routing key, defaults to DefaultMetadataKey
+}
+
+// routingKey is the context key of the routing key set with WithRoutingKey.
+type routingKey struct{}
+
+// WithRoutingKey returns a context that routes RPCs by the key. It takes precedence over the
+// routing key in the outgoing metadata.
+func WithRoutingKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, routingKey{}, key)
+}
+
+// UnaryClientInterceptor returns an interceptor that routes unary RPCs by a key taken from the
+// request message, e.g. a user ID field. An empty key leaves the context unchanged.
+func UnaryClientInterceptor(key func(method string, req any) string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if k := key(method, req); k != "" {
+			ctx = WithRoutingKey(ctx, k)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// builder builds a ring balancer for every client connection.
+type builder struct {
+	config Config
+}
+
+// NewBuilder returns a balancer builder with the given config, to be registered with
+// balancer.Register. It returns the ring's config errors, such as ch.ErrUnknownHash.
+func NewBuilder(cfg Config) (balancer.Builder, error) {
+	if cfg.Name == "" {
+		cfg.Name = Name
+	}
+	if cfg.MetadataKey == "" {
+		cfg.MetadataKey = DefaultMetadataKey
+	}
+	if cfg.Ring.HashFunction == nil && cfg.Ring.HashName == "" {
+		cfg.Ring.HashName = DefaultHashName
+	}
+	if cfg.Ring.ReplicationFactor == 0 {
+		cfg.Ring.ReplicationFactor = DefaultReplicationFactor
+	}
+	if _, err := ch.NewWithConfig(cfg.Ring); err != nil {
+		return nil, err
+	}
+	return &builder{config: cfg}, nil
+}
+
+// Build returns a balancer with a ring of its own. SubConns are managed by the base balancer,
+// which calls back into the picker builder whenever the set of READY SubConns changes.
+func (b *builder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	ring, _ := ch.NewWithConfig(b.config.Ring)
+	pb := &pickerBuilder{ring: ring, metadataKey: b.config.MetadataKey}
+	return &ringBalancer{
+		Balancer: base.NewBalancerBuilder(b.config.Name, pb, base.Config{}).Build(cc, opts),
+		ring:     ring,
+	}
+}
+
+// Name returns the name of the balancer.
+func (b *builder) Name() string {
+	return b.config.Name
+}
+
+// ringBalancer keeps the ring in sync with the resolver addresses.
+type ringBalancer struct {
+	balancer.Balancer
+	ring *ch.ConsistentHashing
+}
+
+// UpdateClientConnState adds new and removes vanished addresses from the ring in a single
+// batch, then lets the base balancer create and shut down the SubConns.
+func (b *ringBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	addrs := make(map[string]bool, len(s.ResolverState.Addresses))
+	var changes []ch.Change
+	for _, a := range s.ResolverState.Addresses {
+		addrs[a.Addr] = true
+	}
+	current := make(map[string]bool)
+	for _, host := range b.ring.Hosts() {
+		current[host] = true
+		if !addrs[host] {
+			changes = append(changes, ch.Change{Op: ch.ChangeRemove, Host: host})
+		}
+	}
+	for addr := range addrs {
+		if !current[addr] {
+			changes = append(changes, ch.Change{Op: ch.ChangeAdd, Host: addr})
+		}
+	}
+	if err := b.ring.Apply(context.Background(), changes); err != nil {
+		return err
+	}
+	return b.Balancer.UpdateClientConnState(s)
+}
+
+// pickerBuilder builds pickers over the READY SubConns of a balancer.
+type pickerBuilder struct {
+	ring        *ch.ConsistentHashing
+	metadataKey string
+}
+
+// Build returns a picker over the READY SubConns.
+func (pb *pickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	p := &picker{ring: pb.ring, metadataKey: pb.metadataKey, ready: make(map[string]balancer.SubConn, len(info.ReadySCs))}
+	for sc, sci := range info.ReadySCs {
+		p.ready[sci.Address.Addr] = sc
+		p.addrs = append(p.addrs, sci.Address.Addr)
+	}
+	return p
+}
+
+// picker picks the first READY replica of the routing key within its bounded load.
+type picker struct {
+	ring        *ch.ConsistentHashing
+	metadataKey string
+	ready       map[string]balancer.SubConn // READY SubConns by address
+	addrs       []string                    // addresses of the READY SubConns
+	next        uint32                      // round robin counter for RPCs without a routing key
+}
+
+// Pick returns the SubConn of the first replica of the RPC's routing key that is READY,
+// preferring replicas within their bounded load. RPCs without a routing key are spread round
+// robin. The pick counts towards the backend's load until the RPC is done.
+func (p *picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	host, ok := p.pick(info.Ctx, p.routingKey(info.Ctx))
+	if !ok {
+		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+	}
+
+	// Hold the load with a lease on the backend as it is in the ring now, so the load is given
+	// back to it even if the backend leaves the ring and joins again before the RPC is done.
+	// A backend that left the ring since it was picked waits for the next picker.
+	lease, err := p.ring.AcquireHost(info.Ctx, host)
+	if err != nil {
+		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+	}
+	return balancer.PickResult{
+		SubConn: p.ready[host],
+		Done: func(balancer.DoneInfo) {
+			lease.Release()
+		},
+	}, nil
+}
+
+// pick returns the address of the backend for the routing key.
+func (p *picker) pick(ctx context.Context, key string) (string, bool) {
+	if key == "" {
+		return p.addrs[atomic.AddUint32(&p.next, 1)%uint32(len(p.addrs))], true
+	}
+
+	// The first replica is usually READY, so only walk the ring when it is not.
+	if host, err := p.ring.GetLeast(ctx, key); err == nil && p.ready[host] != nil {
+		return host, true
+	}
+	hosts, err := p.replicas(ctx, key)
+	if err != nil {
+		return "", false
+	}
+	for _, host := range hosts {
+		if p.ready[host] != nil {
+			return host, true
+		}
+	}
+	return "", false
+}
+
+// replicas returns every host of the ring for the key in preference order.
+func (p *picker) replicas(ctx context.Context, key string) ([]string, error) {
+	for {
+		// Backends removed since counting them make the lookup fail, count them again.
+		hosts, err := p.ring.GetLeastN(ctx, key, len(p.ring.Hosts()))
+		if err != ch.ErrInsufficientHosts {
+			return hosts, err
+		}
+	}
+}
+
+// routingKey returns the routing key of the RPC from its context or outgoing metadata.
+func (p *picker) routingKey(ctx context.Context) string {
+	if key, ok := ctx.Value(routingKey{}).(string); ok {
+		return key
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if values := md.Get(p.metadataKey); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,243 @@
+package grpcbalancer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	ch "github.com/ArchishmanSengupta/consistent-hashing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// backend is a health server that names itself in the response header and can hold RPCs.
+type backend struct {
+	healthpb.UnimplementedHealthServer
+	name     string
+	listener *bufconn.Listener
+	server   *grpc.Server
+	hold     chan struct{} // RPCs for the "hold" service block until it is closed
+	held     chan string   // receives the backend name of every held RPC
+}
+
+func (b *backend) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	grpc.SetHeader(ctx, metadata.Pairs("backend", b.name))
+	if req.Service == "hold" {
+		b.held <- b.name
+		<-b.hold
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+// newCluster starts n in-process backends and returns a client connection balanced over them.
+func newCluster(t *testing.T, n int) ([]*backend, *grpc.ClientConn, *manual.Resolver) {
+	var backends []*backend
+	var addrs []resolver.Address
+	listeners := make(map[string]*bufconn.Listener)
+	hold, held := make(chan struct{}), make(chan string, 100)
+	for i := 0; i < n; i++ {
+		b := &backend{name: fmt.Sprintf("backend%d", i), listener: bufconn.Listen(1 << 20), server: grpc.NewServer(), hold: hold, held: held}
+		healthpb.RegisterHealthServer(b.server, b)
+		go b.server.Serve(b.listener)
+		t.Cleanup(b.server.Stop)
+		backends = append(backends, b)
+		listeners[b.name] = b.listener
+		addrs = append(addrs, resolver.Address{Addr: b.name})
+	}
+
+	r := manual.NewBuilderWithScheme("test")
+	r.InitialState(resolver.State{Addresses: addrs})
+	conn, err := grpc.NewClient(r.Scheme()+":///ring",
+		grpc.WithResolvers(r),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return listeners[addr].DialContext(ctx)
+		}),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"consistent_hashing": {}}]}`),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	// RPCs without a routing key go round robin, so every backend answers once all are READY.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	seen := make(map[string]bool)
+	for len(seen) < n {
+		name, err := call(ctx, conn, "")
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		seen[name] = true
+	}
+	return backends, conn, r
+}
+
+// call issues a health check for the service and returns the name of the backend serving it.
+func call(ctx context.Context, conn *grpc.ClientConn, service string) (string, error) {
+	var header metadata.MD
+	_, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service}, grpc.Header(&header), grpc.WaitForReady(true))
+	if err != nil {
+		return "", err
+	}
+	return header.Get("backend")[0], nil
+}
+
+func TestBalancerStickyKeys(t *testing.T) {
+	_, conn, _ := newCluster(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The same key always reaches the same backend, whether set in the context or metadata.
+	used := make(map[string]bool)
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("user%d", i)
+		first, err := call(WithRoutingKey(ctx, key), conn, "")
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		for j := 0; j < 3; j++ {
+			again, _ := call(metadata.AppendToOutgoingContext(ctx, DefaultMetadataKey, key), conn, "")
+			if again != first {
+				t.Errorf("Key %s: expected %s, got %s", key, first, again)
+			}
+		}
+		used[first] = true
+	}
+	if len(used) != 3 {
+		t.Errorf("Expected keys to spread over 3 backends, got %v", used)
+	}
+}
+
+func TestBalancerSkipsNotReady(t *testing.T) {
+	backends, conn, r := newCluster(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	owner, err := call(WithRoutingKey(ctx, "key"), conn, "")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	// Stopping the owner moves the key to the next replica.
+	for _, b := range backends {
+		if b.name == owner {
+			b.server.Stop()
+		}
+	}
+	var next string
+	for next == "" || next == owner {
+		if next, err = call(WithRoutingKey(ctx, "key"), conn, ""); err != nil && ctx.Err() != nil {
+			t.Fatalf("Expected the key to move to another backend, got %v", err)
+		}
+	}
+
+	// Removing the owner from the resolver removes it from the ring.
+	var addrs []resolver.Address
+	for _, b := range backends {
+		if b.name != owner {
+			addrs = append(addrs, resolver.Address{Addr: b.name})
+		}
+	}
+	r.UpdateState(resolver.State{Addresses: addrs})
+	if got, _ := call(WithRoutingKey(ctx, "key"), conn, ""); got != next {
+		t.Errorf("Expected the key to stay on %s, got %s", next, got)
+	}
+}
+
+func TestBalancerBoundedLoad(t *testing.T) {
+	backends, conn, _ := newCluster(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	owner, _ := call(WithRoutingKey(ctx, "key"), conn, "")
+
+	// Hold many RPCs for the same key; they spill over once the owner exceeds its bounded load.
+	var wg sync.WaitGroup
+	served := make(map[string]int)
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			call(WithRoutingKey(ctx, "key"), conn, "hold")
+		}()
+		// Wait for the RPC to reach its backend so the next pick sees its load.
+		served[<-backends[0].held]++
+	}
+	close(backends[0].hold)
+	wg.Wait()
+	if len(served) < 2 || served[owner] >= 12 {
+		t.Errorf("Expected RPCs to spill over from %s, got %v", owner, served)
+	}
+
+	// Releasing the RPCs brings the key back to its owner.
+	if got, _ := call(WithRoutingKey(ctx, "key"), conn, ""); got != owner {
+		t.Errorf("Expected the key to return to %s, got %s", owner, got)
+	}
+}
+
+func TestNewBuilder(t *testing.T) {
+	b, err := NewBuilder(Config{Name: "custom_ring"})
+	if err != nil || b.Name() != "custom_ring" {
+		t.Errorf("Expected builder named custom_ring, got %v", err)
+	}
+}
+
+// fakeSubConn stands in for a READY SubConn in picker tests.
+type fakeSubConn struct {
+	balancer.SubConn
+}
+
+func TestPickerLoadFollowsBackendInstance(t *testing.T) {
+	ctx := context.Background()
+	ring, _ := ch.NewWithConfig(ch.Config{HashName: DefaultHashName, ReplicationFactor: DefaultReplicationFactor, LoadFactor: 1.25})
+	ring.AddHosts(ctx, "a", "b")
+	p := &picker{ring: ring, metadataKey: DefaultMetadataKey, ready: map[string]balancer.SubConn{"a": fakeSubConn{}, "b": fakeSubConn{}}}
+
+	host, _ := ring.GetLeast(ctx, "key")
+	result, err := p.Pick(balancer.PickInfo{Ctx: WithRoutingKey(ctx, "key")})
+	if err != nil {
+		t.Fatalf("Pick failed: %v", err)
+	}
+	if load := ring.GetLoads()[host]; load != 1 {
+		t.Errorf("Expected load 1 on %s during the RPC, got %d", host, load)
+	}
+
+	// The backend leaves the ring and joins again before the RPC is done.
+	ring.Remove(ctx, host)
+	ring.Add(ctx, host)
+	ring.IncreaseLoad(ctx, host)
+	result.Done(balancer.DoneInfo{})
+	if load := ring.GetLoads()[host]; load != 1 {
+		t.Errorf("Expected the RPC to leave the new %s at load 1, got %d", host, load)
+	}
+	if err := ring.CheckLoads(); err != nil {
+		t.Errorf("CheckLoads failed: %v", err)
+	}
+
+	// Every backend is still found while backends leave the ring concurrently.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			ring.Remove(ctx, "b")
+			ring.Add(ctx, "b")
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		if _, err := p.replicas(ctx, fmt.Sprintf("key%d", i)); err != nil {
+			t.Errorf("replicas failed: %v", err)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,254 @@
+package consistent_hashing
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+	"unsafe"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dchest/siphash"
+	"github.com/spaolacci/murmur3"
+)
+
+// hashRegistry maps names to hash function constructors, so hash functions can be named in
+// config files and recorded in ring snapshots. Keyed hash functions share the namespace and
+// are built from Config.HashSeed. The catalogue ships fnv64, fnv64a, crc64 (ECMA), sha256
+// (truncated to 64 bits), and the keyed siphash, xxhash64 and murmur3.
+var hashRegistry = struct {
+	sync.RWMutex
+	ctors map[string]func() hash.Hash64
+	keyed map[string]func(seed []byte) (func() hash.Hash64, error)
+}{
+	ctors: map[string]func() hash.Hash64{
+		"fnv64":  fnv.New64,
+		"fnv64a": fnv.New64a,
+		"crc64":  newCRC64,
+		"sha256": newSHA256,
+	},
+	keyed: map[string]func(seed []byte) (func() hash.Hash64, error){
+		"siphash":  newSipHash,
+		"xxhash64": newXXHash,
+		"murmur3":  newMurmur3,
+	},
+}
+
+// RegisterHash registers a hash function constructor under the given name.
+// It returns ErrHashRegistered if the name is already taken.
+func RegisterHash(name string, ctor func() hash.Hash64) error {
+	hashRegistry.Lock()
+	defer hashRegistry.Unlock()
+
+	if registered(name) {
+		return ErrHashRegistered
+	}
+	hashRegistry.ctors[name] = ctor
+	return nil
+}
+
+// RegisterKeyedHash registers a keyed hash function under the given name. The constructor is
+// called with Config.HashSeed and returns the hash function keyed with it, or an error wrapping
+// ErrInvalidSeed if the seed is not valid for the hash function. A nil seed selects the
+// unkeyed variant, if there is one. It returns ErrHashRegistered if the name is already taken.
+func RegisterKeyedHash(name string, ctor func(seed []byte) (func() hash.Hash64, error)) error {
+	hashRegistry.Lock()
+	defer hashRegistry.Unlock()
+
+	if registered(name) {
+		return ErrHashRegistered
+	}
+	hashRegistry.keyed[name] = ctor
+	return nil
+}
+
+// HashByName returns the hash function constructor registered under the given name.
+// Keyed hash functions are returned unkeyed, see KeyedHashByName.
+// It returns ErrUnknownHash if no hash function is registered under the name.
+func HashByName(name string) (func() hash.Hash64, error) {
+	return KeyedHashByName(name, nil)
+}
+
+// KeyedHashByName returns the hash function registered under the given name, keyed with seed.
+// It returns ErrUnknownHash if no hash function is registered under the name, and an error
+// wrapping ErrInvalidSeed if the seed is not valid for it. Hash functions registered with
+// RegisterHash only accept an empty seed.
+func KeyedHashByName(name string, seed []byte) (func() hash.Hash64, error) {
+	hashRegistry.RLock()
+	defer hashRegistry.RUnlock()
+
+	if ctor, ok := hashRegistry.ctors[name]; ok {
+		if len(seed) > 0 {
+			return nil, fmt.Errorf("%w: %s is not a keyed hash function", ErrInvalidSeed, name)
+		}
+		return ctor, nil
+	}
+	if ctor, ok := hashRegistry.keyed[name]; ok {
+		return ctor(seed)
+	}
+	return nil, ErrUnknownHash
+}
+
+// HashNames returns the names of all registered hash functions in sorted order.
+func HashNames() []string {
+	hashRegistry.RLock()
+	defer hashRegistry.RUnlock()
+
+	names := make([]string, 0, len(hashRegistry.ctors)+len(hashRegistry.keyed))
+	for name := range hashRegistry.ctors {
+		names = append(names, name)
+	}
+	for name := range hashRegistry.keyed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registered reports whether a hash function is registered under the name.
+// The caller must hold the registry lock.
+func registered(name string) bool {
+	_, ok := hashRegistry.ctors[name]
+	_, keyed := hashRegistry.keyed[name]
+	return ok || keyed
+}
+
+// crc64Table is the ECMA polynomial table used by the crc64 hash function.
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// newCRC64 returns a CRC-64 with the ECMA polynomial.
+func newCRC64() hash.Hash64 {
+	return crc64.New(crc64Table)
+}
+
+// truncatedSHA256 is SHA-256 truncated to its first 8 bytes, read big-endian.
+type truncatedSHA256 struct {
+	hash.Hash
+}
+
+// newSHA256 returns SHA-256 truncated to 64 bits.
+func newSHA256() hash.Hash64 {
+	return truncatedSHA256{sha256.New()}
+}
+
+// Sum64 returns the first 8 bytes of the SHA-256 digest as a big-endian integer.
+func (h truncatedSHA256) Sum64() uint64 {
+	var buf [sha256.Size]byte
+	return binary.BigEndian.Uint64(h.Sum(buf[:0]))
+}
+
+// newSipHash returns SipHash-2-4 keyed with a 16 byte secret key. SipHash has no unkeyed
+// variant, so the seed is required.
+func newSipHash(seed []byte) (func() hash.Hash64, error) {
+	if len(seed) != 16 {
+		return nil, fmt.Errorf("%w: siphash needs a 16 byte key, got %d bytes", ErrInvalidSeed, len(seed))
+	}
+	key := append([]byte(nil), seed...)
+	return func() hash.Hash64 { return siphash.New(key) }, nil
+}
+
+// newXXHash returns xxHash64 seeded with an 8 byte little-endian seed, or unseeded for a nil seed.
+func newXXHash(seed []byte) (func() hash.Hash64, error) {
+	if len(seed) == 0 {
+		return func() hash.Hash64 { return xxhash.New() }, nil
+	}
+	if len(seed) != 8 {
+		return nil, fmt.Errorf("%w: xxhash64 needs an 8 byte seed, got %d bytes", ErrInvalidSeed, len(seed))
+	}
+	s := binary.LittleEndian.Uint64(seed)
+	return func() hash.Hash64 { return &seededXXHash{Digest: xxhash.NewWithSeed(s), seed: s} }, nil
+}
+
+// seededXXHash is a seeded xxHash64 digest that keeps its seed when it is Reset.
+type seededXXHash struct {
+	*xxhash.Digest
+	seed uint64
+}
+
+// Reset clears the digest's state, keeping the seed.
+func (d *seededXXHash) Reset() {
+	d.ResetWithSeed(d.seed)
+}
+
+// newMurmur3 returns the 64-bit murmur3 seeded with a 4 byte little-endian seed, or unseeded
+// for a nil seed.
+func newMurmur3(seed []byte) (func() hash.Hash64, error) {
+	if len(seed) == 0 {
+		return murmur3.New64, nil
+	}
+	if len(seed) != 4 {
+		return nil, fmt.Errorf("%w: murmur3 needs a 4 byte seed, got %d bytes", ErrInvalidSeed, len(seed))
+	}
+	s := binary.LittleEndian.Uint32(seed)
+	return func() hash.Hash64 { return murmur3.New64WithSeed(s) }, nil
+}
+
+// hashNameOf returns the name the constructor is registered under, or an empty string if it
+// is not registered. Constructors are compared by their function pointer, keyed hash functions
+// only match their unkeyed variant.
+func hashNameOf(ctor func() hash.Hash64) string {
+	hashRegistry.RLock()
+	defer hashRegistry.RUnlock()
+
+	ptr := reflect.ValueOf(ctor).Pointer()
+	for name, fn := range hashRegistry.ctors {
+		if reflect.ValueOf(fn).Pointer() == ptr {
+			return name
+		}
+	}
+	for name, keyed := range hashRegistry.keyed {
+		if fn, err := keyed(nil); err == nil && reflect.ValueOf(fn).Pointer() == ptr {
+			return name
+		}
+	}
+	return ""
+}
+
+// FNV-1a 64-bit parameters, used to hash keys inline without allocating a hash object.
+const (
+	fnv64aOffset = 14695981039346656037
+	fnv64aPrime  = 1099511628211
+)
+
+// hashPool hands out reusable hash objects of a hash function, so hashing a key does not
+// allocate. Hash objects are Reset before every use.
+type hashPool struct {
+	fnv64a bool      // the hash function is fnv.New64a, which is computed inline
+	pool   sync.Pool // pool of hash.Hash64 objects
+}
+
+// newHashPool returns a pool of hash objects created by the constructor.
+func newHashPool(ctor func() hash.Hash64) *hashPool {
+	p := &hashPool{fnv64a: hashNameOf(ctor) == "fnv64a"}
+	p.pool.New = func() interface{} { return ctor() }
+	return p
+}
+
+// sum returns the 64-bit hash of key. The hash object must not retain or modify key.
+func (p *hashPool) sum(key []byte) (uint64, error) {
+	if p.fnv64a {
+		h := uint64(fnv64aOffset)
+		for _, b := range key {
+			h ^= uint64(b)
+			h *= fnv64aPrime
+		}
+		return h, nil
+	}
+
+	h := p.pool.Get().(hash.Hash64)
+	h.Reset()
+	_, err := h.Write(key)
+	sum := h.Sum64()
+	p.pool.Put(h)
+	return sum, err
+}
+
+// stringBytes returns the bytes of s without copying them. The bytes must not be modified.
+func stringBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
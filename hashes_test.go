@@ -0,0 +1,184 @@
+package consistent_hashing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"hash/fnv"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/spaolacci/murmur3"
+)
+
+func testCRC64() hash.Hash64 {
+	return crc64.New(crc64.MakeTable(crc64.ISO))
+}
+
+func TestRegisterHash(t *testing.T) {
+	if err := RegisterHash("test-crc64", testCRC64); err != nil {
+		t.Fatalf("RegisterHash failed: %v", err)
+	}
+	if err := RegisterHash("test-crc64", testCRC64); err != ErrHashRegistered {
+		t.Errorf("Expected ErrHashRegistered, got %v", err)
+	}
+	if _, err := HashByName("test-crc64"); err != nil {
+		t.Errorf("HashByName failed: %v", err)
+	}
+	if _, err := HashByName("nope"); err != ErrUnknownHash {
+		t.Errorf("Expected ErrUnknownHash, got %v", err)
+	}
+
+	ch, err := NewWithConfig(Config{HashName: "test-crc64"})
+	if err != nil {
+		t.Fatalf("NewWithConfig failed: %v", err)
+	}
+	if h, _ := ch.Hash("key"); h != hashWith(testCRC64, "key") {
+		t.Errorf("Expected the registered hash function to be used")
+	}
+	if _, err := NewWithConfig(Config{HashName: "nope"}); err != ErrUnknownHash {
+		t.Errorf("Expected ErrUnknownHash, got %v", err)
+	}
+}
+
+func TestHashNameOf(t *testing.T) {
+	ch, _ := NewWithConfig(Config{HashFunction: fnv.New64a})
+	if ch.ring.Load().config.HashName != "fnv64a" {
+		t.Errorf("Expected fnv64a, got %q", ch.ring.Load().config.HashName)
+	}
+	if name := hashNameOf(func() hash.Hash64 { return fnv.New64() }); name != "" {
+		t.Errorf("Expected no name for an unregistered function, got %q", name)
+	}
+}
+
+func TestKeyedHashes(t *testing.T) {
+	seeds := map[string][]byte{
+		"siphash":  []byte("0123456789abcdef"),
+		"xxhash64": {1, 2, 3, 4, 5, 6, 7, 8},
+		"murmur3":  {1, 2, 3, 4},
+	}
+	for name, seed := range seeds {
+		keyed, err := NewWithConfig(Config{HashName: name, HashSeed: seed})
+		if err != nil {
+			t.Fatalf("%s: NewWithConfig failed: %v", name, err)
+		}
+		same, _ := NewWithConfig(Config{HashName: name, HashSeed: append([]byte(nil), seed...)})
+		other := append([]byte(nil), seed...)
+		other[0]++
+		different, _ := NewWithConfig(Config{HashName: name, HashSeed: other})
+
+		// Hashes are stable across reused hash objects and depend on the seed.
+		first, _ := keyed.Hash("key")
+		second, _ := keyed.Hash("key")
+		if first != second {
+			t.Errorf("%s: expected stable hashes, got %d and %d", name, first, second)
+		}
+		if h, _ := same.Hash("key"); h != first {
+			t.Errorf("%s: expected the same seed to give the same hash", name)
+		}
+		if h, _ := different.Hash("key"); h == first {
+			t.Errorf("%s: expected a different seed to give a different hash", name)
+		}
+
+		// The seed can not be changed through the caller's slice.
+		seed[0]++
+		if h, _ := keyed.Hash("key"); h != first {
+			t.Errorf("%s: expected the ring to keep its own copy of the seed", name)
+		}
+	}
+
+	if h, _ := mustHash(t, Config{HashName: "murmur3", HashSeed: []byte{42, 0, 0, 0}}, "key"); h != murmur3.Sum64WithSeed([]byte("key"), 42) {
+		t.Errorf("Expected murmur3 seeded with 42")
+	}
+	if h, _ := mustHash(t, Config{HashName: "xxhash64"}, "key"); h != xxhash.Sum64String("key") {
+		t.Errorf("Expected unseeded xxhash64")
+	}
+}
+
+func mustHash(t *testing.T, cfg Config, key string) (uint64, error) {
+	ch, err := NewWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewWithConfig failed: %v", err)
+	}
+	return ch.Hash(key)
+}
+
+func TestKeyedHashErrors(t *testing.T) {
+	configs := []Config{
+		{HashName: "siphash"},
+		{HashName: "siphash", HashSeed: []byte("short")},
+		{HashName: "xxhash64", HashSeed: []byte{1, 2, 3}},
+		{HashName: "murmur3", HashSeed: []byte{1, 2, 3}},
+		{HashName: "fnv64a", HashSeed: []byte{1}},
+		{HashSeed: []byte{1}},
+	}
+	for _, cfg := range configs {
+		if _, err := NewWithConfig(cfg); !errors.Is(err, ErrInvalidSeed) {
+			t.Errorf("Expected ErrInvalidSeed for %s with %d byte seed, got %v", cfg.HashName, len(cfg.HashSeed), err)
+		}
+	}
+	if err := RegisterKeyedHash("siphash", newSipHash); err != ErrHashRegistered {
+		t.Errorf("Expected ErrHashRegistered, got %v", err)
+	}
+	if err := RegisterHash("murmur3", murmur3.New64); err != ErrHashRegistered {
+		t.Errorf("Expected ErrHashRegistered, got %v", err)
+	}
+	if name := hashNameOf(murmur3.New64); name != "murmur3" {
+		t.Errorf("Expected murmur3, got %q", name)
+	}
+}
+
+func TestKeyedHashSnapshot(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, HashName: "siphash", HashSeed: []byte("0123456789abcdef")})
+	ch.AddHosts(ctx, "host1", "host2", "host3")
+
+	// A router restored from the snapshot agrees on placement without knowing the seed up front.
+	data, err := ch.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	var restored ConsistentHashing
+	if err := restored.UnmarshalJSON(data); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		want, _ := ch.Get(ctx, key)
+		if got, _ := restored.Get(ctx, key); got != want {
+			t.Errorf("Key %s: expected %s, got %s", key, want, got)
+		}
+	}
+}
+
+func TestHashCatalogue(t *testing.T) {
+	names := HashNames()
+	for _, name := range []string{"fnv64a", "murmur3", "xxhash64", "crc64", "sha256", "siphash"} {
+		found := false
+		for _, n := range names {
+			found = found || n == name
+		}
+		if !found {
+			t.Errorf("Expected %s in %v", name, names)
+		}
+	}
+
+	key := []byte("key")
+	sum := sha256.Sum256(key)
+	expected := map[string]uint64{
+		"fnv64a":   hashWith(fnv.New64a, "key"),
+		"murmur3":  murmur3.Sum64(key),
+		"xxhash64": xxhash.Sum64(key),
+		"crc64":    crc64.Checksum(key, crc64.MakeTable(crc64.ECMA)),
+		"sha256":   binary.BigEndian.Uint64(sum[:8]),
+	}
+	for name, want := range expected {
+		if got, _ := mustHash(t, Config{HashName: name}, "key"); got != want {
+			t.Errorf("%s: expected %d, got %d", name, want, got)
+		}
+	}
+}
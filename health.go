@@ -0,0 +1,239 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Health check defaults, see HealthConfig.
+const (
+	DefaultHealthInterval     = 10 * time.Second
+	DefaultUnhealthyThreshold = 1
+	DefaultHealthyThreshold   = 2
+)
+
+// Checker checks the health of a single host. A nil error means the host is healthy.
+type Checker interface {
+	Check(ctx context.Context, host string) error
+}
+
+// CheckerFunc adapts a function to a Checker.
+type CheckerFunc func(ctx context.Context, host string) error
+
+// Check calls f(ctx, host).
+func (f CheckerFunc) Check(ctx context.Context, host string) error {
+	return f(ctx, host)
+}
+
+// HTTPChecker returns a Checker that sends a GET request for path to every host and treats
+// a 2xx or 3xx response as healthy. Host names without a scheme are requested over http.
+// A nil client uses http.DefaultClient.
+func HTTPChecker(client *http.Client, path string) Checker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return CheckerFunc(func(ctx context.Context, host string) error {
+		base := host
+		if !strings.Contains(base, "://") {
+			base = "http://" + base
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/")+path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		// Drain the body so the connection can be reused by the next check.
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("health check of %s returned %s", host, resp.Status)
+		}
+		return nil
+	})
+}
+
+// TCPChecker returns a Checker that treats a host as healthy if a TCP connection to it can be
+// established. Host names are dialed as they are, so they must be host:port addresses.
+func TCPChecker() Checker {
+	var dialer net.Dialer
+	return CheckerFunc(func(ctx context.Context, host string) error {
+		conn, err := dialer.DialContext(ctx, "tcp", host)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// HealthConfig configures the health checks started with StartHealthChecks.
+type HealthConfig struct {
+	Checker            Checker       // checks a single host, required
+	Interval           time.Duration // time between two rounds of checks, defaults to DefaultHealthInterval
+	Timeout            time.Duration // timeout of a single check, defaults to Interval
+	UnhealthyThreshold int           // consecutive failed checks marking a host unhealthy, defaults to DefaultUnhealthyThreshold
+	HealthyThreshold   int           // consecutive passed checks marking a host healthy again, defaults to DefaultHealthyThreshold
+}
+
+// healthCount counts the consecutive results of the checks of a host.
+type healthCount struct {
+	failures  int
+	successes int
+}
+
+// StartHealthChecks checks every host of the ring with the configured Checker, once right away
+// and then every Interval, until ctx is done. A healthy host failing UnhealthyThreshold checks
+// in a row is marked unhealthy, an unhealthy host passing HealthyThreshold checks in a row is
+// marked healthy again, see SetHealthy. The checks of a round run concurrently.
+// It returns an error wrapping ErrInvalidHealthConfig if the config has no Checker or a negative
+// value, and starts nothing in that case.
+func (c *ConsistentHashing) StartHealthChecks(ctx context.Context, cfg HealthConfig) error {
+	if cfg.Checker == nil {
+		return fmt.Errorf("%w: no checker", ErrInvalidHealthConfig)
+	}
+	if cfg.Interval < 0 || cfg.Timeout < 0 || cfg.UnhealthyThreshold < 0 || cfg.HealthyThreshold < 0 {
+		return fmt.Errorf("%w: negative interval, timeout or threshold", ErrInvalidHealthConfig)
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultHealthInterval
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = cfg.Interval
+	}
+	if cfg.UnhealthyThreshold == 0 {
+		cfg.UnhealthyThreshold = DefaultUnhealthyThreshold
+	}
+	if cfg.HealthyThreshold == 0 {
+		cfg.HealthyThreshold = DefaultHealthyThreshold
+	}
+
+	go func() {
+		// The counts are kept per host, so a host added again under the same name starts over.
+		var counts map[*Host]*healthCount
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			counts = c.checkHealth(ctx, &cfg, counts)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// checkHealth runs one round of checks over the hosts of the current ring and marks the hosts
+// whose consecutive results crossed a threshold. It returns the counts of the current hosts,
+// continuing the counts of the previous round.
+func (c *ConsistentHashing) checkHealth(ctx context.Context, cfg *HealthConfig, counts map[*Host]*healthCount) map[*Host]*healthCount {
+	r := c.current()
+
+	// Check all hosts concurrently.
+	results := make([]error, len(r.hosts))
+	var wg sync.WaitGroup
+	for i, h := range r.hosts {
+		wg.Add(1)
+		go func(i int, h *Host) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+			defer cancel()
+			results[i] = cfg.Checker.Check(checkCtx, h.Name)
+		}(i, h)
+	}
+	wg.Wait()
+
+	// Results of a round cut short by ctx say nothing about the hosts.
+	if ctx.Err() != nil {
+		return counts
+	}
+
+	// Hosts that are no longer in the ring are dropped from the counts.
+	next := make(map[*Host]*healthCount, len(r.hosts))
+	for i, h := range r.hosts {
+		if c.host(h.Name) != h {
+			continue
+		}
+		count := counts[h]
+		if count == nil {
+			count = &healthCount{}
+		}
+		next[h] = count
+
+		if results[i] != nil {
+			count.failures++
+			count.successes = 0
+			if h.healthy() && count.failures >= cfg.UnhealthyThreshold {
+				c.setHealthy(h, false)
+			}
+		} else {
+			count.successes++
+			count.failures = 0
+			if !h.healthy() && count.successes >= cfg.HealthyThreshold {
+				c.setHealthy(h, true)
+			}
+		}
+	}
+	return next
+}
+
+// SetHealthy marks the host healthy or unhealthy. Unhealthy hosts stay in the ring and keep
+// their virtual nodes, so marking a host never moves keys between healthy hosts: lookups skip
+// an unhealthy host for the next healthy host clockwise, and its keys return to it once it is
+// healthy again. If every host is unhealthy, lookups fall back to ignoring health.
+// Changing the health of a host emits EventHostUnhealthy or EventHostHealthy but does not bump
+// the ring version. It returns ErrHostNotFound if the host is not in the ring.
+func (c *ConsistentHashing) SetHealthy(ctx context.Context, host string, healthy bool) error {
+	hostData := c.host(host)
+	if hostData == nil {
+		return ErrHostNotFound
+	}
+	c.setHealthy(hostData, healthy)
+	return nil
+}
+
+// Unhealthy returns the names of the hosts currently marked unhealthy, in the order they were added.
+func (c *ConsistentHashing) Unhealthy() []string {
+	r := c.current()
+	var hosts []string
+	for _, h := range r.hosts {
+		if !h.healthy() {
+			hosts = append(hosts, h.Name)
+		}
+	}
+	return hosts
+}
+
+// setHealthy marks the host healthy or unhealthy and notifies subscribers if that changed it.
+// The ring is republished with the new healthy weight, so the bounded load only spreads the
+// total load over the healthy hosts.
+func (c *ConsistentHashing) setHealthy(h *Host, healthy bool) {
+	from, to, typ := int32(0), int32(1), EventHostUnhealthy
+	if healthy {
+		from, to, typ = 1, 0, EventHostHealthy
+	}
+
+	// Serialize with writers, so every published ring counts the health of its hosts.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if atomic.CompareAndSwapInt32(&h.down, from, to) {
+		c.ring.Store(c.current().withHealth())
+		c.publish(RingEvent{Type: typ, Host: h.Name, Version: c.Version()})
+	}
+}
+
+// healthy reports whether the host is not marked unhealthy.
+func (h *Host) healthy() bool {
+	return atomic.LoadInt32(&h.down) == 0
+}
@@ -0,0 +1,264 @@
+package consistent_hashing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetHealthy(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	ch.AddHosts(ctx, "host1", "host2", "host3", "host4", "host5")
+	events := ch.Subscribe(ctx)
+
+	owners := make(map[string][]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owners[key], _ = ch.GetN(ctx, key, 5)
+	}
+
+	// Keys of an unhealthy host move to the next host clockwise, all other keys stay put.
+	if err := ch.SetHealthy(ctx, "host2", false); err != nil {
+		t.Fatalf("SetHealthy failed: %v", err)
+	}
+	if ev := <-events; ev.Type != EventHostUnhealthy || ev.Host != "host2" {
+		t.Errorf("Expected HostUnhealthy event for host2, got %+v", ev)
+	}
+	for key, replicas := range owners {
+		want := replicas[0]
+		if want == "host2" {
+			want = replicas[1]
+		}
+		if got, _ := ch.Get(ctx, key); got != want {
+			t.Errorf("Key %s: expected %s, got %s", key, want, got)
+		}
+		if got, _ := ch.GetLeast(ctx, key); got == "host2" {
+			t.Errorf("Key %s: expected GetLeast to skip host2", key)
+		}
+		if got, _ := ch.GetN(ctx, key, 5); got[4] != "host2" {
+			t.Errorf("Key %s: expected host2 last, got %v", key, got)
+		}
+		if got, _ := ch.GetLeastN(ctx, key, 5); got[4] != "host2" {
+			t.Errorf("Key %s: expected host2 last, got %v", key, got)
+		}
+	}
+	if unhealthy := ch.Unhealthy(); len(unhealthy) != 1 || unhealthy[0] != "host2" {
+		t.Errorf("Expected [host2] unhealthy, got %v", unhealthy)
+	}
+	if stats := ch.Stats(); stats.PerHost[1].Healthy || !stats.PerHost[0].Healthy {
+		t.Errorf("Expected only host2 unhealthy, got %+v", stats.PerHost)
+	}
+
+	// Marking a host again changes nothing and emits no event.
+	ch.SetHealthy(ctx, "host2", false)
+
+	// Once healthy again, the host gets all of its keys back.
+	ch.SetHealthy(ctx, "host2", true)
+	if ev := <-events; ev.Type != EventHostHealthy || ev.Host != "host2" {
+		t.Errorf("Expected HostHealthy event for host2, got %+v", ev)
+	}
+	for key, replicas := range owners {
+		if got, _ := ch.Get(ctx, key); got != replicas[0] {
+			t.Errorf("Key %s: expected %s, got %s", key, replicas[0], got)
+		}
+	}
+	if ch.Version() != 1 {
+		t.Errorf("Expected health changes not to bump the version, got %d", ch.Version())
+	}
+
+	if err := ch.SetHealthy(ctx, "host6", false); err != ErrHostNotFound {
+		t.Errorf("Expected ErrHostNotFound, got %v", err)
+	}
+}
+
+func TestAllHostsUnhealthy(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	ch.AddHosts(ctx, "host1", "host2", "host3")
+	owners := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owners[key], _ = ch.Get(ctx, key)
+	}
+
+	// Lookups fall back to ignoring health when no host is healthy.
+	for _, host := range ch.Hosts() {
+		ch.SetHealthy(ctx, host, false)
+	}
+	for key, want := range owners {
+		if got, err := ch.Get(ctx, key); err != nil || got != want {
+			t.Errorf("Key %s: expected %s, got %s (%v)", key, want, got, err)
+		}
+		if got, err := ch.GetLeast(ctx, key); err != nil || got == "" {
+			t.Errorf("Key %s: expected a host, got %q (%v)", key, got, err)
+		}
+		if got, err := ch.GetNAcrossDomains(ctx, key, 3, DomainRack); err != nil || len(got) != 3 {
+			t.Errorf("Key %s: expected 3 hosts, got %v (%v)", key, got, err)
+		}
+	}
+}
+
+// flakyChecker fails the checks of the hosts marked as failing and counts the consecutive
+// passed checks of every host.
+type flakyChecker struct {
+	mu        sync.Mutex
+	failing   map[string]bool
+	successes map[string]int
+}
+
+func (f *flakyChecker) Check(ctx context.Context, host string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing[host] {
+		f.successes[host] = 0
+		return errors.New("down")
+	}
+	f.successes[host]++
+	return nil
+}
+
+func (f *flakyChecker) set(host string, failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing[host] = failing
+}
+
+func TestBoundedLoadWithUnhealthyHost(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.AddHosts(ctx, "a", "b", "c")
+	ch.SetHealthy(ctx, "c", false)
+
+	// The load is spread over the healthy hosts only, so they stay within their max load.
+	for i := 0; i < 300; i++ {
+		lease, err := ch.Acquire(ctx, "hot")
+		if err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+		if lease.Host() == "c" {
+			t.Fatalf("Expected no lease on the unhealthy c")
+		}
+		for _, host := range []string{"a", "b"} {
+			if load, max := ch.GetLoads()[host], ch.HostMaxLoad(host); load > max {
+				t.Fatalf("After %d leases: %s has load %d over its max load %d", i+1, host, load, max)
+			}
+		}
+	}
+	if want := int64(math.Ceil(300.0 / 2 * 1.25)); ch.MaxLoad() != want {
+		t.Errorf("Expected max load %d, got %d", want, ch.MaxLoad())
+	}
+
+	// A healthy host takes its share of the load again.
+	ch.SetHealthy(ctx, "c", true)
+	if want := int64(math.Ceil(300.0 / 3 * 1.25)); ch.MaxLoad() != want {
+		t.Errorf("Expected max load %d, got %d", want, ch.MaxLoad())
+	}
+}
+
+func TestStartHealthChecks(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch.AddHosts(ctx, "host1", "host2", "host3")
+	events := ch.Subscribe(ctx)
+
+	checker := &flakyChecker{failing: map[string]bool{"host2": true}, successes: make(map[string]int)}
+	err := ch.StartHealthChecks(ctx, HealthConfig{
+		Checker:            checker,
+		Interval:           5 * time.Millisecond,
+		UnhealthyThreshold: 2,
+		HealthyThreshold:   3,
+	})
+	if err != nil {
+		t.Fatalf("StartHealthChecks failed: %v", err)
+	}
+
+	next := func() RingEvent {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a health event")
+			return RingEvent{}
+		}
+	}
+
+	// The failing host is ejected.
+	if ev := next(); ev.Type != EventHostUnhealthy || ev.Host != "host2" {
+		t.Fatalf("Expected HostUnhealthy event for host2, got %+v", ev)
+	}
+
+	// And restored only after enough consecutive passed checks.
+	checker.set("host2", false)
+	if ev := next(); ev.Type != EventHostHealthy || ev.Host != "host2" {
+		t.Fatalf("Expected HostHealthy event for host2, got %+v", ev)
+	}
+	checker.mu.Lock()
+	successes := checker.successes["host2"]
+	checker.mu.Unlock()
+	if successes < 3 {
+		t.Errorf("Expected host2 restored after at least 3 passed checks, got %d", successes)
+	}
+	if unhealthy := ch.Unhealthy(); len(unhealthy) != 0 {
+		t.Errorf("Expected no unhealthy hosts, got %v", unhealthy)
+	}
+}
+
+func TestStartHealthChecksInvalidConfig(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	if err := ch.StartHealthChecks(ctx, HealthConfig{}); !errors.Is(err, ErrInvalidHealthConfig) {
+		t.Errorf("Expected ErrInvalidHealthConfig, got %v", err)
+	}
+	err := ch.StartHealthChecks(ctx, HealthConfig{Checker: TCPChecker(), Interval: -time.Second})
+	if !errors.Is(err, ErrInvalidHealthConfig) {
+		t.Errorf("Expected ErrInvalidHealthConfig, got %v", err)
+	}
+}
+
+func TestHTTPChecker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	if err := HTTPChecker(nil, "/healthz").Check(ctx, srv.URL); err != nil {
+		t.Errorf("Expected healthy, got %v", err)
+	}
+	if err := HTTPChecker(srv.Client(), "/healthz").Check(ctx, strings.TrimPrefix(srv.URL, "http://")); err != nil {
+		t.Errorf("Expected healthy without scheme, got %v", err)
+	}
+	if err := HTTPChecker(nil, "/ready").Check(ctx, srv.URL); err == nil {
+		t.Errorf("Expected unhealthy for status 503")
+	}
+}
+
+func TestTCPChecker(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	addr := l.Addr().String()
+	ctx := context.Background()
+
+	if err := TCPChecker().Check(ctx, addr); err != nil {
+		t.Errorf("Expected healthy, got %v", err)
+	}
+	l.Close()
+	if err := TCPChecker().Check(ctx, addr); err == nil {
+		t.Errorf("Expected unhealthy after closing the listener")
+	}
+}
@@ -0,0 +1,298 @@
+// Package httpproxy is an HTTP reverse proxy that routes requests to backends with a
+// consistent hashing ring. Every request is hashed by a configurable key, sent to the first
+// replica of the key within its bounded load, and retried on the next replica if that backend
+// fails. The load of every backend is the number of requests it is serving, held with a lease
+// from AcquireHost around every attempt.
+//
+// Host names of the ring are backend base URLs such as "http://10.0.0.1:8080"; names without a
+// scheme are proxied over plain HTTP.
+package httpproxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+
+	ch "github.com/ArchishmanSengupta/consistent-hashing"
+)
+
+// Custom errors
+var (
+	ErrInvalidAttempts   = errors.New("max attempts must not be negative")
+	ErrInvalidBodyBuffer = errors.New("max body buffer must not be negative")
+	errRetryStatus       = errors.New("retryable response status")
+)
+
+// Defaults for the zero values of Config.
+const (
+	DefaultMaxAttempts   = 2
+	DefaultMaxBodyBuffer = 1 << 20
+)
+
+// KeyFunc returns the hash key of a request, or an empty string if the request has none.
+type KeyFunc func(r *http.Request) string
+
+// HeaderKey returns a KeyFunc that reads the key from the named request header.
+func HeaderKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// CookieKey returns a KeyFunc that reads the key from the named cookie.
+func CookieKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// PathSegmentKey returns a KeyFunc that reads the key from the i-th segment of the URL path,
+// counting from 0, so PathSegmentKey(1) returns "42" for "/users/42/profile".
+func PathSegmentKey(i int) KeyFunc {
+	return func(r *http.Request) string {
+		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if i < 0 || i >= len(segments) {
+			return ""
+		}
+		return segments[i]
+	}
+}
+
+// ClientIPKey returns a KeyFunc that uses the IP address of the client connection as the key.
+// Forwarding headers are ignored, since clients can set them to anything.
+func ClientIPKey() KeyFunc {
+	return clientIP
+}
+
+// clientIP returns the IP address of the client connection of the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Config configures a Proxy.
+type Config struct {
+	Key                KeyFunc             // hash key of a request, defaults to ClientIPKey; requests without a key use the client IP
+	MaxAttempts        int                 // no of replicas tried per request, defaults to DefaultMaxAttempts
+	MaxBodyBuffer      int64               // request bodies up to this size are buffered for retries, defaults to DefaultMaxBodyBuffer
+	RetryStatus        func(code int) bool // reports whether a response status is retried on the next replica, nil only retries failed connections
+	RetryNonIdempotent bool                // also retry POST, PATCH and other non-idempotent requests
+	Transport          http.RoundTripper   // transport to the backends, defaults to http.DefaultTransport
+	ErrorLog           *log.Logger         // logger for failed attempts, defaults to the standard logger
+}
+
+// Proxy is an http.Handler that proxies requests to the backends of a ring.
+type Proxy struct {
+	ring    *ch.ConsistentHashing
+	config  Config
+	proxy   *httputil.ReverseProxy
+	targets sync.Map // map of host name to its parsed *url.URL
+}
+
+// attempt is the state of a single attempt to proxy a request to a backend.
+type attempt struct {
+	target *url.URL // backend the request is sent to
+	last   bool     // no more replicas are tried after this attempt
+	err    error    // error of the attempt, nil if the response was written
+}
+
+// attemptKey is the context key of the current attempt of a request.
+type attemptKey struct{}
+
+// New returns a Proxy routing requests to the backends of the ring.
+// It returns ErrInvalidAttempts or ErrInvalidBodyBuffer for negative limits.
+func New(ring *ch.ConsistentHashing, cfg Config) (*Proxy, error) {
+	if cfg.MaxAttempts < 0 {
+		return nil, ErrInvalidAttempts
+	}
+	if cfg.MaxBodyBuffer < 0 {
+		return nil, ErrInvalidBodyBuffer
+	}
+	if cfg.Key == nil {
+		cfg.Key = ClientIPKey()
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.MaxBodyBuffer == 0 {
+		cfg.MaxBodyBuffer = DefaultMaxBodyBuffer
+	}
+
+	p := &Proxy{ring: ring, config: cfg}
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(pr.In.Context().Value(attemptKey{}).(*attempt).target)
+			pr.SetXForwarded()
+		},
+		Transport:      cfg.Transport,
+		ErrorLog:       cfg.ErrorLog,
+		ModifyResponse: p.modifyResponse,
+		// Record the error instead of answering, so the request can be retried.
+		ErrorHandler: func(_ http.ResponseWriter, r *http.Request, err error) {
+			r.Context().Value(attemptKey{}).(*attempt).err = err
+		},
+	}
+	return p, nil
+}
+
+// ServeHTTP proxies the request to the first replica of its key with acceptable load and retries
+// it on the next replicas if the backend can not be reached or returns a retryable status.
+// It answers 503 Service Unavailable if the ring is empty and 502 Bad Gateway if every attempt
+// failed. Bodies larger than MaxBodyBuffer and non-idempotent requests are only tried once.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := p.config.Key(r)
+	if key == "" {
+		key = clientIP(r)
+	}
+
+	// Buffer the body so it can be sent again, unless it is too large.
+	attempts := p.config.MaxAttempts
+	if !p.config.RetryNonIdempotent && !idempotent(r.Method) {
+		attempts = 1
+	}
+	var body []byte
+	if attempts > 1 && r.Body != nil && r.Body != http.NoBody {
+		buf, err := io.ReadAll(io.LimitReader(r.Body, p.config.MaxBodyBuffer+1))
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if int64(len(buf)) > p.config.MaxBodyBuffer {
+			// Stream the rest of the body to a single backend instead.
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+			attempts = 1
+		} else {
+			body = buf
+		}
+	}
+
+	// Pick the replicas in preference order, preferring backends within their bounded load.
+	hosts, err := p.replicas(r.Context(), key, attempts)
+	if err != nil {
+		http.Error(w, "no backend available", http.StatusServiceUnavailable)
+		return
+	}
+
+	for i, host := range hosts {
+		a := &attempt{last: i == len(hosts)-1}
+		if a.target, a.err = p.target(host); a.err == nil {
+			out := r.WithContext(context.WithValue(r.Context(), attemptKey{}, a))
+			if body != nil {
+				out.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			if err := p.serve(w, out, host); err != nil {
+				a.err = err
+			}
+		}
+		if a.err == nil {
+			return
+		}
+		p.logf("httpproxy: %s %s via %s failed: %v", r.Method, r.URL.Path, host, a.err)
+
+		// Stop retrying once the client has gone away.
+		if r.Context().Err() != nil {
+			break
+		}
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+// replicas returns up to n replicas for the key, fewer if the ring has fewer hosts.
+// It returns ch.ErrNoHost if the ring is empty.
+func (p *Proxy) replicas(ctx context.Context, key string, n int) ([]string, error) {
+	for {
+		if hosts := len(p.ring.Hosts()); n > hosts {
+			n = hosts
+		}
+		if n == 0 {
+			return nil, ch.ErrNoHost
+		}
+		// Hosts removed since counting them make the lookup fail, count them again.
+		hosts, err := p.ring.GetLeastN(ctx, key, n)
+		if err != ch.ErrInsufficientHosts {
+			return hosts, err
+		}
+	}
+}
+
+// serve proxies the request to the host, counting it towards the host's load while it runs.
+// The load is held with a lease on the host as it is in the ring now, so it is given back to
+// the same host even if the host is removed and added again meanwhile. It returns an error
+// without proxying if the host has been removed since it was picked.
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request, host string) error {
+	lease, err := p.ring.AcquireHost(r.Context(), host)
+	if err != nil {
+		return err
+	}
+	defer lease.Release()
+
+	p.proxy.ServeHTTP(w, r)
+	return nil
+}
+
+// modifyResponse turns a retryable response status into an error, unless it is the last attempt.
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	a := resp.Request.Context().Value(attemptKey{}).(*attempt)
+	if p.config.RetryStatus != nil && !a.last && p.config.RetryStatus(resp.StatusCode) {
+		return fmt.Errorf("%w: %s", errRetryStatus, resp.Status)
+	}
+	return nil
+}
+
+// target returns the parsed backend URL of the host.
+func (p *Proxy) target(host string) (*url.URL, error) {
+	if target, ok := p.targets.Load(host); ok {
+		return target.(*url.URL), nil
+	}
+	raw := host
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	target, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	p.targets.Store(host, target)
+	return target, nil
+}
+
+// logf logs a failed attempt to the configured logger.
+func (p *Proxy) logf(format string, args ...any) {
+	if p.config.ErrorLog != nil {
+		p.config.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// idempotent reports whether requests with the method can safely be sent twice.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// readCloser reads from a reader and closes a closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
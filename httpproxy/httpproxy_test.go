@@ -0,0 +1,228 @@
+package httpproxy
+
+import (
+	"context"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	ch "github.com/ArchishmanSengupta/consistent-hashing"
+)
+
+// newBackend returns a test server answering with its name and the request body.
+func newBackend(t *testing.T, name string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, name+":"+string(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newRing returns a ring where the key is owned by the first backend, followed by the second.
+func newRing(t *testing.T, key string, first, second string) *ch.ConsistentHashing {
+	ring, _ := ch.NewWithConfig(ch.Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	h, _ := ring.Hash(key)
+	ring.AddWithTokens(context.Background(), first, []uint64{h})
+	ring.AddWithTokens(context.Background(), second, []uint64{h + 1<<63})
+	return ring
+}
+
+// quietLog discards the log output of failed attempts.
+var quietLog = log.New(io.Discard, "", 0)
+
+func do(t *testing.T, handler http.Handler, method, target, body string, header http.Header) (int, string) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestProxyRoutesByKey(t *testing.T) {
+	a, b := newBackend(t, "a"), newBackend(t, "b")
+	ring := newRing(t, "user1", a.URL, b.URL)
+	p, _ := New(ring, Config{Key: HeaderKey("X-User")})
+
+	for i := 0; i < 3; i++ {
+		code, body := do(t, p, http.MethodGet, "/", "", http.Header{"X-User": {"user1"}})
+		if code != http.StatusOK || body != "a:" {
+			t.Errorf("Expected 200 from a, got %d %q", code, body)
+		}
+	}
+
+	// Host names without a scheme are proxied over HTTP.
+	ring, _ = ch.NewWithConfig(ch.Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ring.Add(context.Background(), strings.TrimPrefix(b.URL, "http://"))
+	p, _ = New(ring, Config{})
+	if code, body := do(t, p, http.MethodGet, "/", "", nil); code != http.StatusOK || body != "b:" {
+		t.Errorf("Expected 200 from b, got %d %q", code, body)
+	}
+}
+
+func TestProxyTracksLoad(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	defer slow.Close()
+	ring := newRing(t, "key", slow.URL, newBackend(t, "b").URL)
+	p, _ := New(ring, Config{Key: HeaderKey("X-Key")})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		do(t, p, http.MethodGet, "/", "", http.Header{"X-Key": {"key"}})
+	}()
+
+	// The request counts towards the backend's load while it is in flight.
+	<-started
+	if load := ring.GetLoads()[slow.URL]; load != 1 {
+		t.Errorf("Expected load 1 during the request, got %d", load)
+	}
+	close(release)
+	wg.Wait()
+	if load := ring.GetLoads()[slow.URL]; load != 0 {
+		t.Errorf("Expected load 0 after the request, got %d", load)
+	}
+}
+
+func TestProxyLoadFollowsHostInstance(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	defer slow.Close()
+	ring := newRing(t, "key", slow.URL, newBackend(t, "b").URL)
+	p, _ := New(ring, Config{Key: HeaderKey("X-Key")})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		do(t, p, http.MethodGet, "/", "", http.Header{"X-Key": {"key"}})
+	}()
+
+	// The backend is removed and added again while the request is in flight.
+	<-started
+	ctx := context.Background()
+	ring.Remove(ctx, slow.URL)
+	ring.Add(ctx, slow.URL)
+	ring.IncreaseLoad(ctx, slow.URL)
+	close(release)
+	wg.Wait()
+
+	// Finishing the request does not take load off the new instance of the backend.
+	if load := ring.GetLoads()[slow.URL]; load != 1 {
+		t.Errorf("Expected load 1 on the re-added backend, got %d", load)
+	}
+	if err := ring.CheckLoads(); err != nil {
+		t.Errorf("CheckLoads failed: %v", err)
+	}
+}
+
+func TestProxyRetries(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	b := newBackend(t, "b")
+	ring := newRing(t, "key", dead.URL, b.URL)
+	p, _ := New(ring, Config{Key: PathSegmentKey(0), ErrorLog: quietLog})
+
+	// The body is replayed to the next replica.
+	if code, body := do(t, p, http.MethodPut, "/key/1", "payload", nil); code != http.StatusOK || body != "b:payload" {
+		t.Errorf("Expected 200 from b with the body, got %d %q", code, body)
+	}
+	if load := ring.GetLoads()[dead.URL]; load != 0 {
+		t.Errorf("Expected the failed attempt to release its load, got %d", load)
+	}
+
+	// Non-idempotent requests and bodies too large to buffer are only tried once.
+	if code, _ := do(t, p, http.MethodPost, "/key", "payload", nil); code != http.StatusBadGateway {
+		t.Errorf("Expected 502 for a POST, got %d", code)
+	}
+	p, _ = New(ring, Config{Key: PathSegmentKey(0), MaxBodyBuffer: 4, ErrorLog: quietLog})
+	if code, _ := do(t, p, http.MethodPut, "/key", "payload", nil); code != http.StatusBadGateway {
+		t.Errorf("Expected 502 for a large body, got %d", code)
+	}
+	p, _ = New(ring, Config{Key: PathSegmentKey(0), RetryNonIdempotent: true, ErrorLog: quietLog})
+	if code, body := do(t, p, http.MethodPost, "/key", "payload", nil); code != http.StatusOK || body != "b:payload" {
+		t.Errorf("Expected 200 from b for a retried POST, got %d %q", code, body)
+	}
+
+	// Every replica failing is a bad gateway.
+	p, _ = New(ring, Config{Key: PathSegmentKey(0), MaxAttempts: 1, ErrorLog: quietLog})
+	if code, _ := do(t, p, http.MethodGet, "/key", "", nil); code != http.StatusBadGateway {
+		t.Errorf("Expected 502 with a single attempt, got %d", code)
+	}
+}
+
+func TestProxyRetryStatus(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	ring := newRing(t, "key", unavailable.URL, newBackend(t, "b").URL)
+	retry5xx := func(code int) bool { return code >= 500 }
+
+	p, _ := New(ring, Config{Key: CookieKey("session"), RetryStatus: retry5xx, ErrorLog: quietLog})
+	cookie := http.Header{"Cookie": {"session=key"}}
+	if code, body := do(t, p, http.MethodGet, "/", "", cookie); code != http.StatusOK || body != "b:" {
+		t.Errorf("Expected 200 from b, got %d %q", code, body)
+	}
+
+	// The last attempt passes the response through.
+	p, _ = New(ring, Config{Key: CookieKey("session"), RetryStatus: retry5xx, MaxAttempts: 1})
+	if code, _ := do(t, p, http.MethodGet, "/", "", cookie); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 from the backend, got %d", code)
+	}
+}
+
+func TestProxyNoBackend(t *testing.T) {
+	ring, _ := ch.NewWithConfig(ch.Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	p, _ := New(ring, Config{})
+	if code, _ := do(t, p, http.MethodGet, "/", "", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", code)
+	}
+	if _, err := New(ring, Config{MaxAttempts: -1}); err != ErrInvalidAttempts {
+		t.Errorf("Expected ErrInvalidAttempts, got %v", err)
+	}
+	if _, err := New(ring, Config{MaxBodyBuffer: -1}); err != ErrInvalidBodyBuffer {
+		t.Errorf("Expected ErrInvalidBodyBuffer, got %v", err)
+	}
+}
+
+func TestKeyFuncs(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/42/profile", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Tenant", "acme")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+
+	tests := []struct {
+		name string
+		key  KeyFunc
+		want string
+	}{
+		{"header", HeaderKey("X-Tenant"), "acme"},
+		{"missing header", HeaderKey("X-Other"), ""},
+		{"cookie", CookieKey("session"), "s1"},
+		{"missing cookie", CookieKey("other"), ""},
+		{"path segment", PathSegmentKey(1), "42"},
+		{"missing path segment", PathSegmentKey(3), ""},
+		{"client ip", ClientIPKey(), "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		if got := tt.key(req); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
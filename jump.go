@@ -0,0 +1,130 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// JumpHash places keys with Jump Consistent Hash.
+// Hosts are numbered buckets, so it needs no memory per key or virtual node and moves the
+// minimal amount of keys when hosts are added. Removing a host moves the last bucket into
+// the removed host's slot, which moves the keys of both hosts.
+// Paper: https://arxiv.org/abs/1406.2294
+type JumpHash struct {
+	config Config
+	hosts  []string     // hosts indexed by bucket number
+	mu     sync.RWMutex // Mutex for synchronizing access
+}
+
+// NewJumpHash creates a new Jump Consistent Hash balancer.
+func NewJumpHash(cfg Config) (*JumpHash, error) {
+	cfg, err := withDefaults(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &JumpHash{config: cfg}, nil
+}
+
+// Add adds a host as the next bucket.
+func (j *JumpHash) Add(ctx context.Context, host string) error {
+	if host == "" {
+		return ErrInvalidHost
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if indexOf(j.hosts, host) >= 0 {
+		return fmt.Errorf("%w: %s", ErrHostExists, host)
+	}
+	j.hosts = append(j.hosts, host)
+	return nil
+}
+
+// Remove removes a host by moving the last bucket into its slot.
+func (j *JumpHash) Remove(ctx context.Context, host string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	i := indexOf(j.hosts, host)
+	if i < 0 {
+		return ErrHostNotFound
+	}
+	last := len(j.hosts) - 1
+	j.hosts[i] = j.hosts[last]
+	j.hosts = j.hosts[:last]
+	return nil
+}
+
+// Get retrieves the host whose bucket the key jumps to.
+func (j *JumpHash) Get(ctx context.Context, key string) (string, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if len(j.hosts) == 0 {
+		return "", ErrNoHost
+	}
+	return j.hosts[jumpHash(hashWith(j.config.HashFunction, key), len(j.hosts))], nil
+}
+
+// GetN retrieves n distinct hosts for the key. The first host is the one Get returns, the
+// following ones are found by jumping with reseeded key hashes. If the reseeded hashes keep
+// landing on already chosen buckets, the remaining buckets are taken in order.
+func (j *JumpHash) GetN(ctx context.Context, key string, n int) ([]string, error) {
+	if n < 0 {
+		return nil, ErrInvalidReplicaCount
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if len(j.hosts) == 0 {
+		return nil, ErrNoHost
+	}
+	if n > len(j.hosts) {
+		return nil, ErrInsufficientHosts
+	}
+
+	replicas := make([]string, 0, n)
+	seen := make(map[int]struct{}, n)
+	h := hashWith(j.config.HashFunction, key)
+	first := jumpHash(h, len(j.hosts))
+	for seed := uint64(1); len(replicas) < n && seed <= uint64(8*len(j.hosts)); seed++ {
+		b := jumpHash(h, len(j.hosts))
+		if _, ok := seen[b]; !ok {
+			seen[b] = struct{}{}
+			replicas = append(replicas, j.hosts[b])
+		}
+		h = hashSeeded(j.config.HashFunction, key, seed)
+	}
+
+	// Fill up deterministically with the buckets following the first one.
+	for i := 1; len(replicas) < n; i++ {
+		b := (first + i) % len(j.hosts)
+		if _, ok := seen[b]; !ok {
+			seen[b] = struct{}{}
+			replicas = append(replicas, j.hosts[b])
+		}
+	}
+
+	return replicas, nil
+}
+
+// Hosts returns the list of current hosts in bucket order.
+func (j *JumpHash) Hosts() []string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return append([]string(nil), j.hosts...)
+}
+
+// jumpHash maps key to a bucket in [0, buckets) as described in the Jump Consistent Hash paper.
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,35 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"testing"
+)
+
+func TestJumpHashGrowth(t *testing.T) {
+	// Growing the number of buckets only moves keys into the new bucket.
+	for i := uint64(0); i < 1000; i++ {
+		key := hashWith(fnv.New64a, fmt.Sprintf("key%d", i))
+		before, after := jumpHash(key, 10), jumpHash(key, 11)
+		if before != after && after != 10 {
+			t.Errorf("Key %d moved from bucket %d to %d", key, before, after)
+		}
+	}
+}
+
+func TestJumpHashRemove(t *testing.T) {
+	j, _ := NewJumpHash(Config{})
+	ctx := context.Background()
+	for _, host := range []string{"host1", "host2", "host3", "host4"} {
+		j.Add(ctx, host)
+	}
+	j.Remove(ctx, "host2")
+	expected := []string{"host1", "host4", "host3"}
+	for i, host := range j.Hosts() {
+		if host != expected[i] {
+			t.Errorf("Expected buckets %v, got %v", expected, j.Hosts())
+			break
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package consistent_hashing
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"math"
+	"net"
+	"strconv"
+)
+
+// ketamaPointsPerServer is the no of points libmemcached places for a server of average weight.
+const ketamaPointsPerServer = 160
+
+// ketamaDefaultPort is memcached's default port, which libmemcached leaves out of the names it hashes.
+const ketamaDefaultPort = "11211"
+
+// Ketama positions live in a 32-bit space. They are stored in the upper half of the 64-bit ring,
+// so the clockwise order and the owner of every key are the same as in the 32-bit ring, while
+// migration plans and ownership statistics still see the whole ring.
+const ketamaShift = 32
+
+// ketamaHash returns the ketama position of a key: the first 4 bytes of its MD5 digest read
+// little-endian, scaled to the 64-bit ring.
+func ketamaHash(key []byte) uint64 {
+	digest := md5.Sum(key)
+	return uint64(binary.LittleEndian.Uint32(digest[:4])) << ketamaShift
+}
+
+// ketamaPoints returns the ketama positions of a host with the given weight in a ring of n hosts
+// with the given total weight, exactly as libmemcached's weighted ketama distribution computes
+// them. The host gets floor(weight / totalWeight * 40 * n) MD5 digests of "name-i", see
+// ketamaName, and every digest yields 4 points, so hosts of equal weight get 160 points each.
+func ketamaPoints(host string, weight int, totalWeight int64, n int) []uint64 {
+	host = ketamaName(host)
+
+	// Mirror libmemcached's single precision arithmetic so rounding matches.
+	pct := float32(weight) / float32(totalWeight)
+	perServer := pct * ketamaPointsPerServer / 4 * float32(n)
+	digests := int(math.Floor(float64(float32(float64(perServer) + 0.0000000001))))
+
+	points := make([]uint64, 0, digests*4)
+	buf := make([]byte, 0, len(host)+12)
+	for i := 0; i < digests; i++ {
+		buf = strconv.AppendInt(append(append(buf[:0], host...), '-'), int64(i), 10)
+		digest := md5.Sum(buf)
+		for x := 0; x < 4; x++ {
+			point := uint32(digest[3+x*4])<<24 | uint32(digest[2+x*4])<<16 | uint32(digest[1+x*4])<<8 | uint32(digest[x*4])
+			points = append(points, uint64(point)<<ketamaShift)
+		}
+	}
+	return points
+}
+
+// ketamaName returns the name libmemcached hashes for a server: "host" for a server on the
+// default port 11211 or without a port, "host:port" for any other port. IPv6 addresses are
+// named without their brackets, as libmemcached stores them.
+func ketamaName(server string) string {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		return server
+	}
+	if port == ketamaDefaultPort {
+		return host
+	}
+	return host + ":" + port
+}
+
+// placeKetama adds the ketama positions of every host without explicit tokens to the placement.
+// Ketama does not resolve collisions: hosts sharing a position keep it, and the host with the
+// smaller name wins lookups there. Hosts with explicit tokens do not count towards the weights.
+func placeKetama(p *placement, hosts []*Host, weights []int) {
+	var totalWeight int64
+	n := 0
+	for i, h := range hosts {
+		if h.Tokens == nil {
+			totalWeight += int64(weights[i])
+			n++
+		}
+	}
+
+	for i, h := range hosts {
+		if h.Tokens == nil {
+			p.vnodes[h.Name] = ketamaPoints(h.Name, weights[i], totalWeight, n)
+		}
+	}
+}
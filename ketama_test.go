@@ -0,0 +1,141 @@
+package consistent_hashing
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// referenceKetama is a direct port of libmemcached's weighted ketama continuum construction and
+// lookup, kept deliberately naive to cross-check the ring's ketama mode. Unlike libketama,
+// libmemcached leaves the default port 11211 out of the names it hashes.
+func referenceKetama(servers []string, weights []int, key string) string {
+	type mcsServer struct {
+		point uint32
+		ip    string
+	}
+	var memory int
+	for _, w := range weights {
+		memory += w
+	}
+
+	var continuum []mcsServer
+	for i, server := range servers {
+		pct := float32(weights[i]) / float32(memory)
+		ks := int(math.Floor(float64(float32(float64(pct*160/4*float32(len(servers))) + 0.0000000001))))
+		for k := 0; k < ks; k++ {
+			digest := md5.Sum([]byte(fmt.Sprintf("%s-%d", strings.TrimSuffix(server, ":11211"), k)))
+			for h := 0; h < 4; h++ {
+				point := uint32(digest[3+h*4])<<24 | uint32(digest[2+h*4])<<16 | uint32(digest[1+h*4])<<8 | uint32(digest[h*4])
+				continuum = append(continuum, mcsServer{point: point, ip: server})
+			}
+		}
+	}
+	sort.SliceStable(continuum, func(i, j int) bool { return continuum[i].point < continuum[j].point })
+
+	digest := md5.Sum([]byte(key))
+	h := uint32(digest[3])<<24 | uint32(digest[2])<<16 | uint32(digest[1])<<8 | uint32(digest[0])
+	for _, s := range continuum {
+		if s.point >= h {
+			return s.ip
+		}
+	}
+	return continuum[0].ip
+}
+
+func TestKetamaMatchesReference(t *testing.T) {
+	ctx := context.Background()
+	servers := []string{"10.0.1.1:11211", "10.0.1.2:11212", "10.0.1.3:11211", "10.0.1.4", "10.0.1.5:11213"}
+	weights := []int{1, 1, 2, 1, 3}
+
+	ch, _ := NewWithConfig(Config{Ketama: true})
+	for i, server := range servers {
+		ch.AddWithWeight(ctx, server, weights[i])
+	}
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		want := referenceKetama(servers, weights, key)
+		if got, _ := ch.Get(ctx, key); got != want {
+			t.Fatalf("Key %s: expected %s, got %s", key, want, got)
+		}
+	}
+
+	// Removing a server recomputes the whole continuum, as libmemcached does.
+	ch.Remove(ctx, servers[2])
+	servers = append(servers[:2], servers[3:]...)
+	weights = append(weights[:2], weights[3:]...)
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		want := referenceKetama(servers, weights, key)
+		if got, _ := ch.Get(ctx, key); got != want {
+			t.Fatalf("Key %s after removal: expected %s, got %s", key, want, got)
+		}
+	}
+}
+
+func TestKetamaPoints(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{Ketama: true, ReplicationFactor: 3})
+	ch.AddHosts(ctx, "a", "b", "c")
+
+	// Equal weights get 160 points per server regardless of ReplicationFactor.
+	if stats := ch.Stats(); stats.VNodes != 480 || stats.Collisions != 0 {
+		t.Errorf("Expected 480 vnodes and no collisions, got %+v", stats)
+	}
+
+	// The 4 points of a digest are read little-endian from consecutive 4 byte groups.
+	digest := md5.Sum([]byte("a-0"))
+	points := ketamaPoints("a", 1, 3, 3)
+	for x := 0; x < 4; x++ {
+		want := uint64(digest[x*4]) | uint64(digest[x*4+1])<<8 | uint64(digest[x*4+2])<<16 | uint64(digest[x*4+3])<<24
+		if points[x] != want<<ketamaShift {
+			t.Errorf("Point %d: expected %d, got %d", x, want<<ketamaShift, points[x])
+		}
+	}
+
+	// Snapshots record the ketama mode.
+	data, _ := ch.MarshalBinary()
+	var restored ConsistentHashing
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		want, _ := ch.Get(ctx, key)
+		if got, _ := restored.Get(ctx, key); got != want {
+			t.Errorf("Key %s: expected %s, got %s", key, want, got)
+		}
+	}
+}
+
+func TestKetamaLibmemcachedNames(t *testing.T) {
+	// First points of libmemcached's continuum, the little-endian words of MD5("10.0.1.1-0"),
+	// MD5("10.0.1.1-1") and MD5("10.0.1.2:11212-0"): the default port is left out of the name.
+	tests := []struct {
+		server string
+		want   []uint32
+	}{
+		{"10.0.1.1:11211", []uint32{2383802539, 488362977, 2185284489, 383925769, 2656459490, 1132345447, 3306680326, 3022260113}},
+		{"10.0.1.1", []uint32{2383802539, 488362977, 2185284489, 383925769, 2656459490, 1132345447, 3306680326, 3022260113}},
+		{"10.0.1.2:11212", []uint32{811425674, 2166097311, 1911690777, 378887151}},
+	}
+	for _, tt := range tests {
+		points := ketamaPoints(tt.server, 1, 1, 1)
+		for i, want := range tt.want {
+			if points[i] != uint64(want)<<ketamaShift {
+				t.Errorf("%s point %d: expected %d, got %d", tt.server, i, want, points[i]>>ketamaShift)
+			}
+		}
+	}
+
+	if got := ketamaName("[::1]:11211"); got != "::1" {
+		t.Errorf("Expected ::1, got %s", got)
+	}
+	if got := ketamaName("[::1]:11212"); got != "::1:11212" {
+		t.Errorf("Expected ::1:11212, got %s", got)
+	}
+}
@@ -0,0 +1,111 @@
+package consistent_hashing
+
+import (
+	"context"
+	"sync"
+)
+
+// Lease is a unit of load held on a host, acquired with Acquire and given back with Release.
+type Lease struct {
+	c    *ConsistentHashing
+	host *Host         // host the load is held on
+	once sync.Once     // makes Release idempotent
+	done chan struct{} // closed on release to stop waiting for the context
+}
+
+// Acquire picks a host for the key like GetLeast, increments its load and returns a lease
+// holding that load. The load is given back by the lease's Release method, or automatically
+// once ctx is done, so a panicking caller can not leak load as long as its context ends.
+// It returns ErrNoHost if no hosts are added, and ctx.Err() if ctx is already done.
+func (c *ConsistentHashing) Acquire(ctx context.Context, key string) (*Lease, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var host *Host
+	for {
+		// Load the current ring, it is never modified after it has been published.
+		r := c.current()
+		if len(r.points) == 0 {
+			return nil, ErrNoHost
+		}
+		h, err := r.hash(key)
+		if err != nil {
+			return nil, err
+		}
+
+		// Take the load on the host picked within the bounded load. If the host has been
+		// removed in the meantime, pick again from the new ring.
+		host = r.hosts[c.leastIndex(r, h)]
+		load, err := c.addLoad(host, 1)
+		if err == ErrHostNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.publishLoad(host.Name, load)
+		break
+	}
+	return c.lease(ctx, host), nil
+}
+
+// AcquireHost increments the load of the named host and returns a lease holding that load, for
+// callers that pick the host themselves, e.g. from GetLeastN. The lease holds load on the host
+// as it is in the ring now, so releasing it never changes the load of a host of the same name
+// added after this one has been removed. It returns ErrHostNotFound if the host is not in the
+// ring, and ctx.Err() if ctx is already done.
+func (c *ConsistentHashing) AcquireHost(ctx context.Context, host string) (*Lease, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	hostData := c.host(host)
+	if hostData == nil {
+		return nil, ErrHostNotFound
+	}
+	load, err := c.addLoad(hostData, 1)
+	if err != nil {
+		return nil, err
+	}
+	c.publishLoad(host, load)
+	return c.lease(ctx, hostData), nil
+}
+
+// lease returns a lease holding one unit of load already taken on the host.
+func (c *ConsistentHashing) lease(ctx context.Context, host *Host) *Lease {
+	l := &Lease{c: c, host: host, done: make(chan struct{})}
+
+	// Give the load back once the context is done, unless the lease is released first.
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				l.Release()
+			case <-l.done:
+			}
+		}()
+	}
+	return l
+}
+
+// Host returns the name of the host the lease holds load on.
+func (l *Lease) Host() string {
+	return l.host.Name
+}
+
+// Release gives the load of the lease back to its host. Only the first call has an effect.
+// If the host has been removed from the ring since, even if a host of the same name has been
+// added again, releasing does not change any load.
+func (l *Lease) Release() {
+	l.once.Do(func() {
+		close(l.done)
+
+		// Removing the host took its load out of the total load already.
+		load, err := l.c.addLoad(l.host, -1)
+		if err != nil {
+			return
+		}
+		l.c.publishLoad(l.host.Name, load)
+	})
+}
@@ -0,0 +1,180 @@
+package consistent_hashing
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcquireRelease(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	if _, err := ch.Acquire(ctx, "key1"); err != ErrNoHost {
+		t.Errorf("Expected ErrNoHost, got %v", err)
+	}
+	ch.Add(ctx, "host1")
+	ch.Add(ctx, "host2")
+
+	want, _ := ch.GetLeast(ctx, "key1")
+	lease, err := ch.Acquire(ctx, "key1")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if lease.Host() != want {
+		t.Errorf("Expected lease on %s, got %s", want, lease.Host())
+	}
+	if load := ch.GetLoads()[lease.Host()]; load != 1 {
+		t.Errorf("Expected load 1, got %d", load)
+	}
+
+	// Releasing twice only gives the load back once.
+	lease.Release()
+	lease.Release()
+	if load := ch.GetLoads()[lease.Host()]; load != 0 {
+		t.Errorf("Expected load 0, got %d", load)
+	}
+	if ch.totalLoad != 0 {
+		t.Errorf("Expected total load 0, got %d", ch.totalLoad)
+	}
+}
+
+func TestAcquireBoundedLoad(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.AddHosts(ctx, "host1", "host2", "host3")
+
+	// Leases for a hot key spill over to other hosts once the owner is saturated.
+	hosts := make(map[string]int)
+	var leases []*Lease
+	for i := 0; i < 12; i++ {
+		lease, _ := ch.Acquire(ctx, "hot")
+		hosts[lease.Host()]++
+		leases = append(leases, lease)
+	}
+	if len(hosts) < 2 {
+		t.Errorf("Expected leases on several hosts, got %v", hosts)
+	}
+	for _, lease := range leases {
+		lease.Release()
+	}
+	for host, load := range ch.GetLoads() {
+		if load != 0 {
+			t.Errorf("Expected load 0 on %s, got %d", host, load)
+		}
+	}
+}
+
+func TestAcquireContext(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.Add(context.Background(), "host1")
+
+	// A done context acquires nothing.
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ch.Acquire(cancelled, "key1"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// The load is given back once the context is done, even if the caller panics.
+	ctx, cancel := context.WithCancel(context.Background())
+	func() {
+		defer func() { recover() }()
+		defer cancel()
+		ch.Acquire(ctx, "key1")
+		panic("caller failed")
+	}()
+	deadline := time.Now().Add(time.Second)
+	for ch.GetLoads()["host1"] != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the lease to be released after cancel, load %d", ch.GetLoads()["host1"])
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReleaseAfterRemove(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.Add(ctx, "host1")
+	lease, _ := ch.Acquire(ctx, "key1")
+
+	// A host of the same name added again does not inherit the lease.
+	ch.Remove(ctx, "host1")
+	ch.Add(ctx, "host1")
+	ch.IncreaseLoad(ctx, "host1")
+	lease.Release()
+	if load := ch.GetLoads()["host1"]; load != 1 {
+		t.Errorf("Expected release to leave the new host1 at load 1, got %d", load)
+	}
+}
+
+func TestAcquireHost(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.AddHosts(ctx, "host1", "host2")
+
+	lease, err := ch.AcquireHost(ctx, "host2")
+	if err != nil {
+		t.Fatalf("AcquireHost failed: %v", err)
+	}
+	if lease.Host() != "host2" || ch.GetLoads()["host2"] != 1 {
+		t.Errorf("Expected a lease on host2 with load 1, got %s with %v", lease.Host(), ch.GetLoads())
+	}
+	if _, err := ch.AcquireHost(ctx, "host3"); err != ErrHostNotFound {
+		t.Errorf("Expected ErrHostNotFound, got %v", err)
+	}
+
+	// The lease stays with the host instance it was taken on.
+	ch.Remove(ctx, "host2")
+	ch.Add(ctx, "host2")
+	ch.IncreaseLoad(ctx, "host2")
+	lease.Release()
+	if load := ch.GetLoads()["host2"]; load != 1 {
+		t.Errorf("Expected release to leave the new host2 at load 1, got %d", load)
+	}
+	if err := ch.CheckLoads(); err != nil {
+		t.Errorf("CheckLoads failed: %v", err)
+	}
+}
+
+func TestConcurrentLeases(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.AddHosts(ctx, "host1", "host2", "host3")
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				lease, err := ch.Acquire(ctx, "key")
+				if err != nil {
+					t.Errorf("Acquire failed: %v", err)
+					return
+				}
+				// Release concurrently with the context watcher and a second release.
+				go lease.Release()
+				lease.Release()
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		var sum int64
+		for _, load := range ch.GetLoads() {
+			sum += load
+		}
+		if sum == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected all leases to be released, total load %d", sum)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,104 @@
+package consistent_hashing
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// removedLoad marks the load of a host that has been removed from the ring. Lookups and load
+// changes may still hold the host of an older ring; the marker makes them see the removal, so
+// a load change racing with the removal fails instead of leaking into the total load.
+const removedLoad = math.MinInt64
+
+// loadOf returns the current load of the host, 0 once it has been removed.
+func loadOf(h *Host) int64 {
+	load := atomic.LoadInt64(&h.Load)
+	if load == removedLoad {
+		return 0
+	}
+	return load
+}
+
+// addLoad atomically adds delta to the load of the host and to the total load and returns the
+// new load of the host. It returns ErrHostNotFound if the host has been removed and
+// ErrNegativeLoad if the load would drop below 0, leaving both loads unchanged.
+func (c *ConsistentHashing) addLoad(h *Host, delta int64) (int64, error) {
+	for {
+		load := atomic.LoadInt64(&h.Load)
+		if load == removedLoad {
+			return 0, ErrHostNotFound
+		}
+		if load+delta < 0 {
+			return 0, ErrNegativeLoad
+		}
+		if atomic.CompareAndSwapInt64(&h.Load, load, load+delta) {
+			atomic.AddInt64(&c.totalLoad, delta)
+			return load + delta, nil
+		}
+	}
+}
+
+// setLoad atomically replaces the load of the host and adjusts the total load by the
+// difference. It returns ErrHostNotFound if the host has been removed.
+func (c *ConsistentHashing) setLoad(h *Host, load int64) error {
+	for {
+		old := atomic.LoadInt64(&h.Load)
+		if old == removedLoad {
+			return ErrHostNotFound
+		}
+		if atomic.CompareAndSwapInt64(&h.Load, old, load) {
+			atomic.AddInt64(&c.totalLoad, load-old)
+			return nil
+		}
+	}
+}
+
+// retire marks the host as removed and takes its load out of the total load.
+// It must be called after the ring without the host has been published.
+func (c *ConsistentHashing) retire(h *Host) {
+	if load := atomic.SwapInt64(&h.Load, removedLoad); load != removedLoad {
+		atomic.AddInt64(&c.totalLoad, -load)
+	}
+}
+
+// CheckLoads verifies the load accounting of the ring: the total load must equal the sum of the
+// host loads and no load may be negative. It returns an error wrapping ErrLoadMismatch otherwise.
+// Load changes made while checking can make the check fail spuriously, so it is meant for tests
+// and for quiet periods.
+func (c *ConsistentHashing) CheckLoads() error {
+	r := c.current()
+	var sum int64
+	for _, h := range r.hosts {
+		load := loadOf(h)
+		if load < 0 {
+			return fmt.Errorf("%w: %s has load %d", ErrLoadMismatch, h.Name, load)
+		}
+		sum += load
+	}
+	if total := atomic.LoadInt64(&c.totalLoad); total != sum {
+		return fmt.Errorf("%w: total is %d, hosts sum to %d", ErrLoadMismatch, total, sum)
+	}
+	return nil
+}
+
+// Reconcile resets the total load to the sum of the host loads and returns the drift it
+// corrected, the old total minus the sum. Load changes made while reconciling are kept, but
+// may be counted in the drift.
+func (c *ConsistentHashing) Reconcile() int64 {
+	// Hold off writers so no host is retired while summing.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.current()
+	for {
+		total := atomic.LoadInt64(&c.totalLoad)
+		var sum int64
+		for _, h := range r.hosts {
+			sum += loadOf(h)
+		}
+		if atomic.CompareAndSwapInt64(&c.totalLoad, total, sum) {
+			return total - sum
+		}
+	}
+}
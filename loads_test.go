@@ -0,0 +1,176 @@
+package consistent_hashing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"testing"
+)
+
+func TestRemoveReleasesLoad(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	ch.AddHosts(ctx, "host1", "host2", "host3")
+	ch.UpdateLoad(ctx, "host1", 5)
+	ch.UpdateLoad(ctx, "host2", 3)
+	ch.IncreaseLoad(ctx, "host3")
+
+	// Removing a host takes its load out of the total load.
+	ch.Remove(ctx, "host1")
+	if ch.totalLoad != 4 {
+		t.Errorf("Expected total load 4, got %d", ch.totalLoad)
+	}
+	if err := ch.CheckLoads(); err != nil {
+		t.Errorf("CheckLoads failed: %v", err)
+	}
+
+	// So does removing it in a batch.
+	ch.RemoveHosts(ctx, "host2")
+	if ch.totalLoad != 1 {
+		t.Errorf("Expected total load 1, got %d", ch.totalLoad)
+	}
+
+	// A re-added host starts without load.
+	ch.Add(ctx, "host1")
+	if loads := ch.GetLoads(); loads["host1"] != 0 {
+		t.Errorf("Expected load 0 for re-added host1, got %d", loads["host1"])
+	}
+	if err := ch.CheckLoads(); err != nil {
+		t.Errorf("CheckLoads failed: %v", err)
+	}
+}
+
+func TestLoadChangeOnRemovedHost(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	ch.AddHosts(ctx, "host1", "host2")
+	host := ch.host("host1")
+	ch.Remove(ctx, "host1")
+
+	// Load changes still holding the host of an older ring fail and leave the total load alone.
+	if _, err := ch.addLoad(host, 1); err != ErrHostNotFound {
+		t.Errorf("Expected ErrHostNotFound, got %v", err)
+	}
+	if err := ch.setLoad(host, 7); err != ErrHostNotFound {
+		t.Errorf("Expected ErrHostNotFound, got %v", err)
+	}
+	if ch.totalLoad != 0 {
+		t.Errorf("Expected total load 0, got %d", ch.totalLoad)
+	}
+}
+
+func TestNegativeLoad(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	ch.Add(ctx, "host1")
+
+	if err := ch.DecreaseLoad(ctx, "host1"); err != ErrNegativeLoad {
+		t.Errorf("Expected ErrNegativeLoad, got %v", err)
+	}
+	if err := ch.UpdateLoad(ctx, "host1", -1); err != ErrNegativeLoad {
+		t.Errorf("Expected ErrNegativeLoad, got %v", err)
+	}
+	if loads := ch.GetLoads(); loads["host1"] != 0 {
+		t.Errorf("Expected load 0, got %d", loads["host1"])
+	}
+	if ch.totalLoad != 0 {
+		t.Errorf("Expected total load 0, got %d", ch.totalLoad)
+	}
+}
+
+func TestRestoreReplacesLoads(t *testing.T) {
+	ctx := context.Background()
+	src, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25})
+	src.AddHosts(ctx, "host1", "host2")
+	src.UpdateLoad(ctx, "host1", 4)
+	data, _ := src.MarshalBinary()
+
+	dst, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25})
+	dst.AddHosts(ctx, "host1", "host3")
+	dst.UpdateLoad(ctx, "host3", 10)
+	old := dst.host("host3")
+	if err := dst.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if dst.totalLoad != 4 {
+		t.Errorf("Expected total load 4, got %d", dst.totalLoad)
+	}
+
+	// Load changes on the replaced hosts no longer count.
+	if _, err := dst.addLoad(old, -1); err != ErrHostNotFound {
+		t.Errorf("Expected ErrHostNotFound, got %v", err)
+	}
+	if err := dst.CheckLoads(); err != nil {
+		t.Errorf("CheckLoads failed: %v", err)
+	}
+}
+
+func TestCheckLoadsAndReconcile(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	ch.AddHosts(ctx, "host1", "host2")
+	ch.UpdateLoad(ctx, "host1", 2)
+	ch.UpdateLoad(ctx, "host2", 3)
+
+	// Corrupt the total load.
+	ch.totalLoad += 7
+	if err := ch.CheckLoads(); !errors.Is(err, ErrLoadMismatch) {
+		t.Errorf("Expected ErrLoadMismatch, got %v", err)
+	}
+	if drift := ch.Reconcile(); drift != 7 {
+		t.Errorf("Expected drift 7, got %d", drift)
+	}
+	if ch.totalLoad != 5 {
+		t.Errorf("Expected total load 5, got %d", ch.totalLoad)
+	}
+	if err := ch.CheckLoads(); err != nil {
+		t.Errorf("CheckLoads failed: %v", err)
+	}
+	if drift := ch.Reconcile(); drift != 0 {
+		t.Errorf("Expected drift 0, got %d", drift)
+	}
+}
+
+func TestLoadAccountingConcurrency(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	hosts := []string{"host0", "host1", "host2", "host3", "host4", "host5", "host6", "host7"}
+	ch.AddHosts(ctx, hosts...)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				host := hosts[(g+i)%len(hosts)]
+				switch i % 6 {
+				case 0:
+					ch.IncreaseLoad(ctx, host)
+				case 1:
+					ch.DecreaseLoad(ctx, host)
+				case 2:
+					ch.UpdateLoad(ctx, host, int64(i%7))
+				case 3:
+					if l, err := ch.Acquire(ctx, fmt.Sprintf("key%d", i)); err == nil {
+						defer l.Release()
+					}
+				case 4:
+					ch.Remove(ctx, host)
+				case 5:
+					ch.Add(ctx, host)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if err := ch.CheckLoads(); err != nil {
+		t.Errorf("CheckLoads failed: %v", err)
+	}
+	if drift := ch.Reconcile(); drift != 0 {
+		t.Errorf("Expected drift 0, got %d", drift)
+	}
+}
@@ -0,0 +1,173 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Maglev places keys with Maglev hashing. Every host fills slots of a fixed size lookup table
+// following its own permutation, which gives an almost perfectly even spread and O(1) lookups
+// at the cost of rebuilding the table on every membership change.
+// Paper: https://research.google/pubs/pub44824/
+type Maglev struct {
+	config Config
+	hosts  []string     // sorted list of all hosts
+	table  []int        // lookup table of host indexes
+	mu     sync.RWMutex // Mutex for synchronizing access
+}
+
+// NewMaglev creates a new Maglev hashing balancer. Config.MaglevTableSize must be prime,
+// otherwise it returns ErrInvalidTableSize.
+func NewMaglev(cfg Config) (*Maglev, error) {
+	cfg, err := withDefaults(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if !isPrime(cfg.MaglevTableSize) {
+		return nil, ErrInvalidTableSize
+	}
+	return &Maglev{config: cfg}, nil
+}
+
+// Add adds a host and rebuilds the lookup table.
+// It returns ErrInvalidTableSize if the table has no room for another host.
+func (m *Maglev) Add(ctx context.Context, host string) error {
+	if host == "" {
+		return ErrInvalidHost
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if indexOf(m.hosts, host) >= 0 {
+		return fmt.Errorf("%w: %s", ErrHostExists, host)
+	}
+	if len(m.hosts) >= m.config.MaglevTableSize {
+		return ErrInvalidTableSize
+	}
+
+	// Keep the hosts sorted so the table does not depend on the order of Add calls.
+	m.hosts = append(m.hosts, host)
+	sort.Strings(m.hosts)
+	m.populate()
+	return nil
+}
+
+// Remove removes a host and rebuilds the lookup table.
+func (m *Maglev) Remove(ctx context.Context, host string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := indexOf(m.hosts, host)
+	if i < 0 {
+		return ErrHostNotFound
+	}
+	m.hosts = append(m.hosts[:i], m.hosts[i+1:]...)
+	m.populate()
+	return nil
+}
+
+// Get retrieves the host in the table slot of the key.
+func (m *Maglev) Get(ctx context.Context, key string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.hosts) == 0 {
+		return "", ErrNoHost
+	}
+	slot := hashWith(m.config.HashFunction, key) % uint64(len(m.table))
+	return m.hosts[m.table[slot]], nil
+}
+
+// GetN retrieves n distinct hosts for the key by walking the table from the key's slot.
+func (m *Maglev) GetN(ctx context.Context, key string, n int) ([]string, error) {
+	if n < 0 {
+		return nil, ErrInvalidReplicaCount
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.hosts) == 0 {
+		return nil, ErrNoHost
+	}
+	if n > len(m.hosts) {
+		return nil, ErrInsufficientHosts
+	}
+
+	replicas := make([]string, 0, n)
+	seen := make(map[int]struct{}, n)
+	slot := int(hashWith(m.config.HashFunction, key) % uint64(len(m.table)))
+	for i := 0; i < len(m.table) && len(replicas) < n; i++ {
+		host := m.table[(slot+i)%len(m.table)]
+		if _, ok := seen[host]; ok {
+			continue
+		}
+		seen[host] = struct{}{}
+		replicas = append(replicas, m.hosts[host])
+	}
+	return replicas, nil
+}
+
+// Hosts returns the list of current hosts in sorted order.
+func (m *Maglev) Hosts() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.hosts...)
+}
+
+// populate rebuilds the lookup table. Every host walks its own permutation of the table,
+// defined by an offset and a skip derived from its name, and hosts take turns claiming
+// the next free slot of their permutation until the table is full.
+func (m *Maglev) populate() {
+	size := m.config.MaglevTableSize
+	m.table = nil
+	if len(m.hosts) == 0 {
+		return
+	}
+
+	offsets := make([]uint64, len(m.hosts))
+	skips := make([]uint64, len(m.hosts))
+	for i, host := range m.hosts {
+		offsets[i] = hashSeeded(m.config.HashFunction, host, 0) % uint64(size)
+		skips[i] = hashSeeded(m.config.HashFunction, host, 1)%uint64(size-1) + 1
+	}
+
+	table := make([]int, size)
+	for i := range table {
+		table[i] = -1
+	}
+	next := make([]uint64, len(m.hosts))
+	for filled := 0; ; {
+		for i := range m.hosts {
+			// Find the next free slot in the host's permutation.
+			slot := (offsets[i] + next[i]*skips[i]) % uint64(size)
+			for table[slot] >= 0 {
+				next[i]++
+				slot = (offsets[i] + next[i]*skips[i]) % uint64(size)
+			}
+			table[slot] = i
+			next[i]++
+			filled++
+			if filled == size {
+				m.table = table
+				return
+			}
+		}
+	}
+}
+
+// isPrime reports whether n is a prime number.
+func isPrime(n int) bool {
+	if n < 2 {
+		return false
+	}
+	for i := 2; i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestNewMaglev(t *testing.T) {
+	if _, err := NewMaglev(Config{MaglevTableSize: 1000}); err != ErrInvalidTableSize {
+		t.Errorf("Expected ErrInvalidTableSize, got %v", err)
+	}
+	if _, err := NewMaglev(Config{}); err != nil {
+		t.Errorf("NewMaglev failed: %v", err)
+	}
+}
+
+func TestMaglevTable(t *testing.T) {
+	m, _ := NewMaglev(Config{MaglevTableSize: 1031})
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		m.Add(ctx, fmt.Sprintf("host%d", i))
+	}
+
+	// Every host owns an almost equal share of the table.
+	counts := make(map[int]int)
+	for _, host := range m.table {
+		counts[host]++
+	}
+	for host, count := range counts {
+		if count < 1031/7 || count > 1031/7+1 {
+			t.Errorf("Host %s owns %d slots", m.hosts[host], count)
+		}
+	}
+
+	// The table does not depend on the order hosts are added in.
+	other, _ := NewMaglev(Config{MaglevTableSize: 1031})
+	for i := 6; i >= 0; i-- {
+		other.Add(ctx, fmt.Sprintf("host%d", i))
+	}
+	for i := range m.table {
+		if m.table[i] != other.table[i] {
+			t.Fatalf("Tables differ at slot %d", i)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package consistent_hashing
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// KeyRange is a half-open range [Start, End) of the hash space. A range wraps around the end of
+// the hash space when End <= Start, an End of 0 denotes the end of the hash space and a range
+// with Start == End covers the whole hash space.
+type KeyRange struct {
+	Start uint64
+	End   uint64
+}
+
+// Contains reports whether the hash value h falls into the range.
+func (r KeyRange) Contains(h uint64) bool {
+	if r.Start < r.End {
+		return h >= r.Start && h < r.End
+	}
+	return h >= r.Start || h < r.End
+}
+
+// Fraction returns the fraction of the hash space covered by the range.
+func (r KeyRange) Fraction() float64 {
+	if r.Start == r.End {
+		return 1
+	}
+	// Unsigned subtraction takes care of wrapping ranges.
+	return float64(r.End-r.Start) / math.Exp2(64)
+}
+
+// RangeMove is a range of keys that changes owner between two ring states.
+type RangeMove struct {
+	KeyRange
+	From string // owner in the old ring, empty if the old ring had no hosts
+	To   string // owner in the new ring, empty if the new ring has no hosts
+}
+
+// HostMigration is the fraction of the hash space that moves to and away from a host.
+type HostMigration struct {
+	Inbound  float64 // fraction of the hash space the host takes over
+	Outbound float64 // fraction of the hash space the host hands over
+}
+
+// MigrationPlan lists the key ranges that change owner between two ring states.
+type MigrationPlan struct {
+	Moves []RangeMove              // moved ranges in hash order, adjacent ranges are merged
+	Hosts map[string]HostMigration // per host inbound and outbound fractions
+}
+
+// Moved returns the fraction of the hash space that changes owner.
+func (p *MigrationPlan) Moved() float64 {
+	var moved float64
+	for _, m := range p.Moves {
+		moved += m.Fraction()
+	}
+	return moved
+}
+
+// ringPoint is a virtual node position and the host owning it.
+type ringPoint struct {
+	hash uint64
+	host string
+}
+
+// Diff returns the key ranges that change owner when moving from the old to the new ring state.
+// Both rings are expected to use the same hash function.
+func Diff(from, to *ConsistentHashing) *MigrationPlan {
+	oldPoints, newPoints := from.points(), to.points()
+
+	// Every virtual node position of either ring is a boundary where ownership may change.
+	bounds := make([]uint64, 0, len(oldPoints)+len(newPoints))
+	for _, p := range oldPoints {
+		bounds = append(bounds, p.hash)
+	}
+	for _, p := range newPoints {
+		bounds = append(bounds, p.hash)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	bounds = dedup(bounds)
+
+	plan := &MigrationPlan{Hosts: make(map[string]HostMigration)}
+	for i, b := range bounds {
+		// A key hashing to h belongs to the first point >= h, so the segment ending
+		// at bound b covers the keys after the previous bound up to and including b.
+		prev := bounds[(i+len(bounds)-1)%len(bounds)]
+		r := KeyRange{Start: prev + 1, End: b + 1}
+		oldOwner, newOwner := ownerOf(oldPoints, b), ownerOf(newPoints, b)
+		if oldOwner == newOwner {
+			continue
+		}
+
+		// Merge with the previous move if it is adjacent and between the same hosts.
+		if n := len(plan.Moves); n > 0 {
+			last := &plan.Moves[n-1]
+			if last.End == r.Start && last.From == oldOwner && last.To == newOwner {
+				last.End = r.End
+				plan.addFraction(oldOwner, newOwner, r.Fraction())
+				continue
+			}
+		}
+		plan.Moves = append(plan.Moves, RangeMove{KeyRange: r, From: oldOwner, To: newOwner})
+		plan.addFraction(oldOwner, newOwner, r.Fraction())
+	}
+
+	return plan
+}
+
+// PlanAdd returns the migration plan for adding the host with the given options,
+// without changing the ring.
+func (c *ConsistentHashing) PlanAdd(ctx context.Context, host string, opts HostOptions) (*MigrationPlan, error) {
+	next := c.Clone()
+	if err := next.AddWithOptions(ctx, host, opts); err != nil {
+		return nil, err
+	}
+	return Diff(c, next), nil
+}
+
+// PlanRemove returns the migration plan for removing the host, without changing the ring.
+func (c *ConsistentHashing) PlanRemove(ctx context.Context, host string) (*MigrationPlan, error) {
+	next := c.Clone()
+	if err := next.Remove(ctx, host); err != nil {
+		return nil, err
+	}
+	return Diff(c, next), nil
+}
+
+// Clone returns a copy of the ring with the same config, hosts, weights, tokens, topology and loads.
+// Subscribers are not copied.
+func (c *ConsistentHashing) Clone() *ConsistentHashing {
+	r := c.current()
+
+	clone, _ := NewWithConfig(*r.config)
+	for i, hostData := range r.hosts {
+		clone.AddWithOptions(context.Background(), hostData.Name, HostOptions{
+			Weight: r.weights[i],
+			Region: hostData.Region,
+			Zone:   hostData.Zone,
+			Rack:   hostData.Rack,
+			Tokens: hostData.Tokens,
+		})
+		clone.UpdateLoad(context.Background(), hostData.Name, loadOf(hostData))
+	}
+	clone.version = c.Version()
+
+	return clone
+}
+
+// points returns a copy of the virtual node positions and their owners in hash order.
+func (c *ConsistentHashing) points() []ringPoint {
+	r := c.current()
+
+	points := make([]ringPoint, 0, len(r.points))
+	for i, h := range r.points {
+		points = append(points, ringPoint{hash: h, host: r.owner(i).Name})
+	}
+	return points
+}
+
+// addFraction accounts a moved fraction of the hash space to the old and new owner.
+func (p *MigrationPlan) addFraction(from, to string, fraction float64) {
+	if from != "" {
+		m := p.Hosts[from]
+		m.Outbound += fraction
+		p.Hosts[from] = m
+	}
+	if to != "" {
+		m := p.Hosts[to]
+		m.Inbound += fraction
+		p.Hosts[to] = m
+	}
+}
+
+// ownerOf returns the owner of the hash value h in the sorted points, or an empty string
+// if there are no points.
+func ownerOf(points []ringPoint, h uint64) string {
+	if len(points) == 0 {
+		return ""
+	}
+	index := sort.Search(len(points), func(i int) bool { return points[i].hash >= h })
+	return points[index%len(points)].host
+}
+
+// dedup removes consecutive duplicates from a sorted slice.
+func dedup(sorted []uint64) []uint64 {
+	out := sorted[:0]
+	for i, v := range sorted {
+		if i == 0 || v != sorted[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestKeyRange(t *testing.T) {
+	r := KeyRange{Start: 10, End: 20}
+	if !r.Contains(10) || r.Contains(20) {
+		t.Errorf("Expected [10, 20) to contain 10 and not 20")
+	}
+	wrap := KeyRange{Start: math.MaxUint64 - 9, End: 10}
+	if !wrap.Contains(math.MaxUint64) || !wrap.Contains(0) || wrap.Contains(10) {
+		t.Errorf("Expected wrapping range to contain the end and start of the hash space")
+	}
+	if f := (KeyRange{Start: 0, End: 1 << 63}).Fraction(); f != 0.5 {
+		t.Errorf("Expected fraction 0.5, got %f", f)
+	}
+	if f := (KeyRange{Start: 5, End: 5}).Fraction(); f != 1 {
+		t.Errorf("Expected fraction 1, got %f", f)
+	}
+}
+
+func TestPlanAdd(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 50})
+	ctx := context.Background()
+	for _, host := range []string{"host1", "host2", "host3"} {
+		ch.Add(ctx, host)
+	}
+
+	plan, err := ch.PlanAdd(ctx, "host4", HostOptions{})
+	if err != nil {
+		t.Fatalf("PlanAdd failed: %v", err)
+	}
+	if len(ch.Hosts()) != 3 {
+		t.Errorf("PlanAdd must not change the ring, got hosts %v", ch.Hosts())
+	}
+
+	// Every range moves to the new host and nothing moves away from it.
+	for _, m := range plan.Moves {
+		if m.To != "host4" {
+			t.Errorf("Expected move to host4, got %+v", m)
+		}
+	}
+	if plan.Hosts["host4"].Outbound != 0 {
+		t.Errorf("Expected no outbound for host4")
+	}
+	if math.Abs(plan.Hosts["host4"].Inbound-plan.Moved()) > 1e-9 {
+		t.Errorf("Expected host4 inbound %f to match moved %f", plan.Hosts["host4"].Inbound, plan.Moved())
+	}
+
+	// The plan matches the actual key movement.
+	next := ch.Clone()
+	next.Add(ctx, "host4")
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		h, _ := ch.Hash(key)
+		old, _ := ch.Get(ctx, key)
+		now, _ := next.Get(ctx, key)
+		moved := false
+		for _, m := range plan.Moves {
+			if m.Contains(h) {
+				moved = true
+				if m.From != old || m.To != now {
+					t.Fatalf("Key %s: expected move %s -> %s, plan has %+v", key, old, now, m)
+				}
+			}
+		}
+		if moved != (old != now) {
+			t.Fatalf("Key %s: moved %v but plan says %v", key, old != now, moved)
+		}
+	}
+}
+
+func TestPlanRemove(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 50})
+	ctx := context.Background()
+	for _, host := range []string{"host1", "host2", "host3"} {
+		ch.Add(ctx, host)
+	}
+	plan, err := ch.PlanRemove(ctx, "host2")
+	if err != nil {
+		t.Fatalf("PlanRemove failed: %v", err)
+	}
+	for _, m := range plan.Moves {
+		if m.From != "host2" {
+			t.Errorf("Expected move from host2, got %+v", m)
+		}
+	}
+	if _, err := ch.PlanRemove(ctx, "host4"); err != ErrHostNotFound {
+		t.Errorf("Expected ErrHostNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MultiProbe places keys with multi-probe consistent hashing. Every host has a single point
+// on the ring and every key is hashed MultiProbeCount times; the probe closest to the next
+// host point clockwise wins. This gives an even spread without virtual nodes.
+// Paper: https://arxiv.org/abs/1505.00062
+type MultiProbe struct {
+	config Config
+	points []uint64          // sorted hash values of the hosts
+	owners map[uint64]string // map of hash value to host
+	hosts  []string          // list of all hosts
+	mu     sync.RWMutex      // Mutex for synchronizing access
+}
+
+// NewMultiProbe creates a new multi-probe consistent hashing balancer.
+func NewMultiProbe(cfg Config) (*MultiProbe, error) {
+	cfg, err := withDefaults(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiProbe{config: cfg, owners: make(map[uint64]string)}, nil
+}
+
+// Add adds a host with a single point on the ring.
+func (m *MultiProbe) Add(ctx context.Context, host string) error {
+	if host == "" {
+		return ErrInvalidHost
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if indexOf(m.hosts, host) >= 0 {
+		return fmt.Errorf("%w: %s", ErrHostExists, host)
+	}
+	h := m.point(host)
+	if owner, ok := m.owners[h]; ok {
+		return fmt.Errorf("%w: position %d is taken by %s", ErrVNodeCollision, h, owner)
+	}
+	m.hosts = append(m.hosts, host)
+	m.owners[h] = host
+	m.points = append(m.points, h)
+	sort.Slice(m.points, func(i, j int) bool { return m.points[i] < m.points[j] })
+	return nil
+}
+
+// Remove removes a host and its point from the ring.
+func (m *MultiProbe) Remove(ctx context.Context, host string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := indexOf(m.hosts, host)
+	if i < 0 {
+		return ErrHostNotFound
+	}
+	m.hosts = append(m.hosts[:i], m.hosts[i+1:]...)
+
+	h := m.point(host)
+	delete(m.owners, h)
+	if p := m.search(h); m.points[p] == h {
+		m.points = append(m.points[:p], m.points[p+1:]...)
+	}
+	return nil
+}
+
+// Get retrieves the host whose point is closest clockwise to any of the key's probes.
+func (m *MultiProbe) Get(ctx context.Context, key string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.hosts) == 0 {
+		return "", ErrNoHost
+	}
+	return m.owners[m.points[m.probe(key)]], nil
+}
+
+// GetN retrieves n distinct hosts for the key: the host Get returns followed by the next
+// hosts clockwise on the ring.
+func (m *MultiProbe) GetN(ctx context.Context, key string, n int) ([]string, error) {
+	if n < 0 {
+		return nil, ErrInvalidReplicaCount
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.hosts) == 0 {
+		return nil, ErrNoHost
+	}
+	if n > len(m.hosts) {
+		return nil, ErrInsufficientHosts
+	}
+
+	index := m.probe(key)
+	replicas := make([]string, n)
+	for i := range replicas {
+		replicas[i] = m.owners[m.points[(index+i)%len(m.points)]]
+	}
+	return replicas, nil
+}
+
+// Hosts returns the list of current hosts.
+func (m *MultiProbe) Hosts() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.hosts...)
+}
+
+// probe hashes the key MultiProbeCount times and returns the index of the point
+// with the smallest clockwise distance from any of the probes. Probes are scrambled like
+// the host points so that they spread over the whole ring.
+func (m *MultiProbe) probe(key string) int {
+	best, bestDistance := 0, ^uint64(0)
+	for i := 0; i < m.config.MultiProbeCount; i++ {
+		h := mix64(hashSeeded(m.config.HashFunction, key, uint64(i)))
+		index := m.search(h)
+		// Unsigned subtraction wraps around the ring for the last point.
+		if distance := m.points[index] - h; distance < bestDistance {
+			best, bestDistance = index, distance
+		}
+	}
+	return best
+}
+
+// point returns the position of a host on the ring. With a single point per host the
+// hash is scrambled so that similar host names do not end up next to each other.
+func (m *MultiProbe) point(host string) uint64 {
+	return mix64(hashWith(m.config.HashFunction, host))
+}
+
+// search finds the index of the first point clockwise from h.
+func (m *MultiProbe) search(h uint64) int {
+	index := sort.Search(len(m.points), func(i int) bool { return m.points[i] >= h })
+	return index % len(m.points)
+}
@@ -0,0 +1,71 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestMultiProbeMinimalRemoval(t *testing.T) {
+	m, _ := NewMultiProbe(Config{})
+	assertMinimalRemoval(t, m)
+}
+
+func TestMultiProbeClosestProbe(t *testing.T) {
+	m, _ := NewMultiProbe(Config{})
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		m.Add(ctx, fmt.Sprintf("host%d", i))
+	}
+
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("key%d", i)
+
+		// Measure the clockwise distance of every probe to every host point.
+		var want string
+		best := ^uint64(0)
+		for p := 0; p < m.config.MultiProbeCount; p++ {
+			h := mix64(hashSeeded(m.config.HashFunction, key, uint64(p)))
+			for _, point := range m.points {
+				if distance := point - h; distance < best {
+					want, best = m.owners[point], distance
+				}
+			}
+		}
+		if got, _ := m.Get(ctx, key); got != want {
+			t.Errorf("Key %s: expected %s, got %s", key, want, got)
+		}
+
+		// The other replicas follow the winning point clockwise.
+		replicas, _ := m.GetN(ctx, key, 3)
+		index := m.search(m.point(replicas[0]))
+		for k, host := range replicas {
+			if owner := m.owners[m.points[(index+k)%len(m.points)]]; host != owner {
+				t.Errorf("Key %s: expected replica %d on %s, got %s", key, k, owner, host)
+			}
+		}
+	}
+}
+
+func TestMultiProbeSpread(t *testing.T) {
+	m, _ := NewMultiProbe(Config{})
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		m.Add(ctx, fmt.Sprintf("host%d", i))
+	}
+
+	// Without virtual nodes, the probes alone spread the keys evenly.
+	counts := make(map[string]int)
+	for i := 0; i < 20000; i++ {
+		host, _ := m.Get(ctx, fmt.Sprintf("key%d", i))
+		counts[host]++
+	}
+	if len(counts) != 10 {
+		t.Fatalf("Expected keys on 10 hosts, got %v", counts)
+	}
+	for host, count := range counts {
+		if count < 1800 || count > 2200 {
+			t.Errorf("Host %s owns %d of 20000 keys", host, count)
+		}
+	}
+}
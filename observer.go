@@ -0,0 +1,60 @@
+package consistent_hashing
+
+// LookupMethod is the kind of lookup reported to an Observer.
+type LookupMethod int
+
+const (
+	// LookupGet is reported for Get, GetBytes and GetHash.
+	LookupGet LookupMethod = iota + 1
+	// LookupGetLeast is reported for GetLeast and GetLeastBytes.
+	LookupGetLeast
+	// LookupGetN is reported for GetN.
+	LookupGetN
+	// LookupGetLeastN is reported for GetLeastN.
+	LookupGetLeastN
+)
+
+// String returns the name of the lookup method.
+func (m LookupMethod) String() string {
+	switch m {
+	case LookupGet:
+		return "Get"
+	case LookupGetLeast:
+		return "GetLeast"
+	case LookupGetN:
+		return "GetN"
+	case LookupGetLeastN:
+		return "GetLeastN"
+	default:
+		return "Unknown"
+	}
+}
+
+// Observer is notified about lookups and ring events, e.g. to export metrics without making
+// the package depend on a metrics library. Methods are called synchronously on the lookup and
+// update paths, so they must be cheap and safe for concurrent use.
+type Observer interface {
+	// ObserveLookup is called after every successful lookup. spilled reports whether a bounded
+	// load lookup returned a different first host than the owner of the key because the owner
+	// was healthy but saturated. Skipping an unhealthy owner is not a spill.
+	ObserveLookup(method LookupMethod, spilled bool)
+	// ObserveEvent is called for every ring event, whether or not anyone subscribed.
+	ObserveEvent(ev RingEvent)
+}
+
+// SetObserver sets the observer notified about lookups and ring events, replacing the previous
+// one. A nil observer disables notifications, which is the default.
+func (c *ConsistentHashing) SetObserver(o Observer) {
+	if o == nil {
+		c.observer.Store(nil)
+		return
+	}
+	c.observer.Store(&o)
+}
+
+// observeLookup reports a successful lookup to the observer, if any.
+func (c *ConsistentHashing) observeLookup(method LookupMethod, spilled bool) {
+	if o := c.observer.Load(); o != nil {
+		(*o).ObserveLookup(method, spilled)
+	}
+}
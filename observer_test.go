@@ -0,0 +1,127 @@
+package consistent_hashing
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"testing"
+)
+
+// recordingObserver counts the lookups and events it observes.
+type recordingObserver struct {
+	mu      sync.Mutex
+	lookups map[LookupMethod]int
+	spilled int
+	events  []EventType
+}
+
+func (o *recordingObserver) ObserveLookup(method LookupMethod, spilled bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lookups[method]++
+	if spilled {
+		o.spilled++
+	}
+}
+
+func (o *recordingObserver) ObserveEvent(ev RingEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, ev.Type)
+}
+
+func TestObserver(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	o := &recordingObserver{lookups: make(map[LookupMethod]int)}
+	ch.SetObserver(o)
+
+	ch.Add(ctx, "host1")
+	ch.Add(ctx, "host2")
+	ch.Get(ctx, "key1")
+	ch.GetBytes(ctx, []byte("key1"))
+	ch.GetN(ctx, "key1", 2)
+
+	// Saturate the owner of the key so bounded load lookups spill over.
+	owner, _ := ch.Get(ctx, "key1")
+	ch.IncreaseLoad(ctx, owner)
+	if host, _ := ch.GetLeast(ctx, "key1"); host == owner {
+		t.Fatalf("Expected GetLeast to spill over from %s", owner)
+	}
+	ch.GetLeastN(ctx, "key1", 2)
+	ch.Remove(ctx, "host2")
+
+	// Failed lookups are not reported.
+	ch.GetN(ctx, "key1", 2)
+
+	want := map[LookupMethod]int{LookupGet: 3, LookupGetLeast: 1, LookupGetN: 1, LookupGetLeastN: 1}
+	for method, count := range want {
+		if o.lookups[method] != count {
+			t.Errorf("Expected %d %s lookups, got %d", count, method, o.lookups[method])
+		}
+	}
+	if o.spilled != 2 {
+		t.Errorf("Expected 2 spilled lookups, got %d", o.spilled)
+	}
+	wantEvents := []EventType{EventHostAdded, EventHostAdded, EventLoadChanged, EventHostRemoved}
+	if len(o.events) != len(wantEvents) {
+		t.Fatalf("Expected events %v, got %v", wantEvents, o.events)
+	}
+	for i := range wantEvents {
+		if o.events[i] != wantEvents[i] {
+			t.Errorf("Event %d: expected %s, got %s", i, wantEvents[i], o.events[i])
+		}
+	}
+
+	// A nil observer stops notifications.
+	ch.SetObserver(nil)
+	ch.Get(ctx, "key1")
+	if o.lookups[LookupGet] != 3 {
+		t.Errorf("Expected no lookups after removing the observer, got %d", o.lookups[LookupGet])
+	}
+}
+
+func TestObserverUnhealthyOwner(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.AddHosts(ctx, "host1", "host2", "host3")
+	o := &recordingObserver{lookups: make(map[LookupMethod]int)}
+	ch.SetObserver(o)
+
+	// Skipping an unhealthy owner moves the key without spilling over.
+	owner, _ := ch.Get(ctx, "key1")
+	ch.SetHealthy(ctx, owner, false)
+	if host, _ := ch.GetLeast(ctx, "key1"); host == owner {
+		t.Fatalf("Expected GetLeast to skip the unhealthy %s", owner)
+	}
+	if hosts, _ := ch.GetLeastN(ctx, "key1", 2); hosts[0] == owner {
+		t.Fatalf("Expected GetLeastN to skip the unhealthy %s", owner)
+	}
+	if o.spilled != 0 {
+		t.Errorf("Expected no spilled lookups, got %d", o.spilled)
+	}
+
+	// A saturated healthy owner still spills over.
+	ch.SetHealthy(ctx, owner, true)
+	ch.IncreaseLoad(ctx, owner)
+	ch.GetLeast(ctx, "key1")
+	ch.GetLeastN(ctx, "key1", 2)
+	if o.spilled != 2 {
+		t.Errorf("Expected 2 spilled lookups, got %d", o.spilled)
+	}
+}
+
+func TestLookupMethodString(t *testing.T) {
+	tests := map[LookupMethod]string{
+		LookupGet:       "Get",
+		LookupGetLeast:  "GetLeast",
+		LookupGetN:      "GetN",
+		LookupGetLeastN: "GetLeastN",
+		LookupMethod(0): "Unknown",
+	}
+	for method, want := range tests {
+		if got := method.String(); got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	}
+}
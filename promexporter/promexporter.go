@@ -0,0 +1,164 @@
+// Package promexporter exports the state of a consistent hashing ring as Prometheus metrics.
+// It lives in its own module so that the ring itself does not depend on Prometheus.
+//
+//	collector, err := promexporter.Register(prometheus.DefaultRegisterer, ring, promexporter.Options{})
+package promexporter
+
+import (
+	"sync/atomic"
+
+	ch "github.com/ArchishmanSengupta/consistent-hashing"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultNamespace prefixes the metric names unless Options.Namespace is set.
+const DefaultNamespace = "consistent_hashing"
+
+// Options configure a Collector.
+type Options struct {
+	Namespace   string            // prefix of the metric names, defaults to DefaultNamespace
+	ConstLabels prometheus.Labels // labels added to every metric, e.g. to tell several rings apart
+}
+
+// Collector exports ring size, vnode count, ring version, per-host load, max load and health, lookup
+// counts by method, bounded-load spillovers and membership changes of a ring.
+// Gauges are read from the ring on every scrape; counters are fed by the ring as an Observer.
+type Collector struct {
+	ring *ch.ConsistentHashing
+
+	// Counters, indexed by lookup method and event type.
+	lookups    [ch.LookupGetLeastN + 1]uint64
+	spillovers uint64
+	changes    [ch.EventBatchApplied + 1]uint64
+
+	hostsDesc       *prometheus.Desc
+	vnodesDesc      *prometheus.Desc
+	versionDesc     *prometheus.Desc
+	maxLoadDesc     *prometheus.Desc
+	hostLoadDesc    *prometheus.Desc
+	hostMaxLoadDesc *prometheus.Desc
+	hostOwnedDesc   *prometheus.Desc
+	hostHealthyDesc *prometheus.Desc
+	lookupsDesc     *prometheus.Desc
+	spilloversDesc  *prometheus.Desc
+	changesDesc     *prometheus.Desc
+}
+
+// New returns a Collector for the ring and installs it as the ring's Observer, replacing any
+// previous observer. The collector still has to be registered, see Register.
+func New(ring *ch.ConsistentHashing, opts Options) *Collector {
+	c := newCollector(ring, opts)
+	ring.SetObserver(c)
+	return c
+}
+
+// Register creates a Collector for the ring and registers it with reg. The collector only
+// replaces the ring's Observer once it is registered.
+func Register(reg prometheus.Registerer, ring *ch.ConsistentHashing, opts Options) (*Collector, error) {
+	c := newCollector(ring, opts)
+	if err := reg.Register(c); err != nil {
+		return nil, err
+	}
+	ring.SetObserver(c)
+	return c, nil
+}
+
+// newCollector returns a Collector for the ring with the metric descriptors for the options.
+func newCollector(ring *ch.ConsistentHashing, opts Options) *Collector {
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, opts.ConstLabels)
+	}
+
+	return &Collector{
+		ring:            ring,
+		hostsDesc:       desc("hosts", "Number of hosts in the ring."),
+		vnodesDesc:      desc("vnodes", "Number of virtual nodes in the ring."),
+		versionDesc:     desc("ring_version", "Ring version, bumped on every membership or weight change."),
+		maxLoadDesc:     desc("max_load", "Maximum allowed load of a host of weight 1."),
+		hostLoadDesc:    desc("host_load", "Current load of the host.", "host"),
+		hostMaxLoadDesc: desc("host_max_load", "Maximum allowed load of the host.", "host"),
+		hostOwnedDesc:   desc("host_owned_ratio", "Fraction of the hash space owned by the host.", "host"),
+		hostHealthyDesc: desc("host_healthy", "Whether the host is healthy (1) or skipped by lookups (0).", "host"),
+		lookupsDesc:     desc("lookups_total", "Number of successful lookups by method.", "method"),
+		spilloversDesc:  desc("spillovers_total", "Number of bounded-load lookups that skipped the saturated owner of the key."),
+		changesDesc:     desc("membership_changes_total", "Number of membership and weight changes by type.", "type"),
+	}
+}
+
+// Describe sends the descriptors of all metrics of the collector.
+func (c *Collector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.hostsDesc
+	descs <- c.vnodesDesc
+	descs <- c.versionDesc
+	descs <- c.maxLoadDesc
+	descs <- c.hostLoadDesc
+	descs <- c.hostMaxLoadDesc
+	descs <- c.hostOwnedDesc
+	descs <- c.hostHealthyDesc
+	descs <- c.lookupsDesc
+	descs <- c.spilloversDesc
+	descs <- c.changesDesc
+}
+
+// Collect reads the current ring state and sends it along with the counters.
+func (c *Collector) Collect(metrics chan<- prometheus.Metric) {
+	stats := c.ring.Stats()
+	metrics <- prometheus.MustNewConstMetric(c.hostsDesc, prometheus.GaugeValue, float64(stats.Hosts))
+	metrics <- prometheus.MustNewConstMetric(c.vnodesDesc, prometheus.GaugeValue, float64(stats.VNodes))
+	metrics <- prometheus.MustNewConstMetric(c.versionDesc, prometheus.GaugeValue, float64(c.ring.Version()))
+	metrics <- prometheus.MustNewConstMetric(c.maxLoadDesc, prometheus.GaugeValue, float64(c.ring.MaxLoad()))
+	for _, h := range stats.PerHost {
+		metrics <- prometheus.MustNewConstMetric(c.hostLoadDesc, prometheus.GaugeValue, float64(h.Load), h.Name)
+		metrics <- prometheus.MustNewConstMetric(c.hostMaxLoadDesc, prometheus.GaugeValue, float64(h.MaxLoad), h.Name)
+		metrics <- prometheus.MustNewConstMetric(c.hostOwnedDesc, prometheus.GaugeValue, h.Owned, h.Name)
+		healthy := 0.0
+		if h.Healthy {
+			healthy = 1
+		}
+		metrics <- prometheus.MustNewConstMetric(c.hostHealthyDesc, prometheus.GaugeValue, healthy, h.Name)
+	}
+
+	for _, method := range []ch.LookupMethod{ch.LookupGet, ch.LookupGetLeast, ch.LookupGetN, ch.LookupGetLeastN} {
+		count := atomic.LoadUint64(&c.lookups[method])
+		metrics <- prometheus.MustNewConstMetric(c.lookupsDesc, prometheus.CounterValue, float64(count), method.String())
+	}
+	metrics <- prometheus.MustNewConstMetric(c.spilloversDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&c.spillovers)))
+	for _, typ := range []ch.EventType{ch.EventHostAdded, ch.EventHostRemoved, ch.EventWeightChanged} {
+		count := atomic.LoadUint64(&c.changes[typ])
+		metrics <- prometheus.MustNewConstMetric(c.changesDesc, prometheus.CounterValue, float64(count), typ.String())
+	}
+}
+
+// ObserveLookup counts a lookup and whether it spilled over. It implements ch.Observer.
+func (c *Collector) ObserveLookup(method ch.LookupMethod, spilled bool) {
+	if method > 0 && int(method) < len(c.lookups) {
+		atomic.AddUint64(&c.lookups[method], 1)
+	}
+	if spilled {
+		atomic.AddUint64(&c.spillovers, 1)
+	}
+}
+
+// ObserveEvent counts membership and weight changes. Batches count every change they apply.
+// It implements ch.Observer.
+func (c *Collector) ObserveEvent(ev ch.RingEvent) {
+	switch ev.Type {
+	case ch.EventHostAdded, ch.EventHostRemoved, ch.EventWeightChanged:
+		atomic.AddUint64(&c.changes[ev.Type], 1)
+	case ch.EventBatchApplied:
+		for _, change := range ev.Changes {
+			switch change.Op {
+			case ch.ChangeAdd:
+				atomic.AddUint64(&c.changes[ch.EventHostAdded], 1)
+			case ch.ChangeRemove:
+				atomic.AddUint64(&c.changes[ch.EventHostRemoved], 1)
+			case ch.ChangeWeight:
+				atomic.AddUint64(&c.changes[ch.EventWeightChanged], 1)
+			}
+		}
+	}
+}
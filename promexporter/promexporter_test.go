@@ -0,0 +1,124 @@
+package promexporter
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"testing"
+
+	ch "github.com/ArchishmanSengupta/consistent-hashing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	ctx := context.Background()
+	ring, _ := ch.NewWithConfig(ch.Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	reg := prometheus.NewRegistry()
+	if _, err := Register(reg, ring, Options{}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// host1 owns the key and half of the ring, host2 the other half.
+	h, _ := ring.Hash("key")
+	ring.AddWithTokens(ctx, "host1", []uint64{h})
+	ring.AddWithTokens(ctx, "host2", []uint64{h + 1<<63})
+	ring.Apply(ctx, []ch.Change{
+		{Op: ch.ChangeAdd, Host: "host3", Options: ch.HostOptions{Tokens: []uint64{h + 1<<62}}},
+		{Op: ch.ChangeWeight, Host: "host2", Options: ch.HostOptions{Weight: 2}},
+	})
+	ring.Remove(ctx, "host3")
+
+	// Saturate host1 so the bounded load lookup of the key spills over to host2.
+	ring.IncreaseLoad(ctx, "host1")
+	ring.Get(ctx, "key")
+	ring.GetHash(ctx, h)
+	if host, _ := ring.GetLeast(ctx, "key"); host != "host2" {
+		t.Fatalf("Expected GetLeast to spill over to host2, got %s", host)
+	}
+	ring.GetN(ctx, "key", 2)
+	ring.SetHealthy(ctx, "host2", false)
+
+	expected := fmt.Sprintf(`
+# HELP consistent_hashing_host_healthy Whether the host is healthy (1) or skipped by lookups (0).
+# TYPE consistent_hashing_host_healthy gauge
+consistent_hashing_host_healthy{host="host1"} 1
+consistent_hashing_host_healthy{host="host2"} 0
+# HELP consistent_hashing_host_load Current load of the host.
+# TYPE consistent_hashing_host_load gauge
+consistent_hashing_host_load{host="host1"} 1
+consistent_hashing_host_load{host="host2"} 0
+# HELP consistent_hashing_host_max_load Maximum allowed load of the host.
+# TYPE consistent_hashing_host_max_load gauge
+consistent_hashing_host_max_load{host="host1"} %d
+consistent_hashing_host_max_load{host="host2"} %d
+# HELP consistent_hashing_host_owned_ratio Fraction of the hash space owned by the host.
+# TYPE consistent_hashing_host_owned_ratio gauge
+consistent_hashing_host_owned_ratio{host="host1"} 0.5
+consistent_hashing_host_owned_ratio{host="host2"} 0.5
+# HELP consistent_hashing_hosts Number of hosts in the ring.
+# TYPE consistent_hashing_hosts gauge
+consistent_hashing_hosts 2
+# HELP consistent_hashing_lookups_total Number of successful lookups by method.
+# TYPE consistent_hashing_lookups_total counter
+consistent_hashing_lookups_total{method="Get"} 2
+consistent_hashing_lookups_total{method="GetLeast"} 1
+consistent_hashing_lookups_total{method="GetLeastN"} 0
+consistent_hashing_lookups_total{method="GetN"} 1
+# HELP consistent_hashing_max_load Maximum allowed load of a host of weight 1.
+# TYPE consistent_hashing_max_load gauge
+consistent_hashing_max_load %d
+# HELP consistent_hashing_membership_changes_total Number of membership and weight changes by type.
+# TYPE consistent_hashing_membership_changes_total counter
+consistent_hashing_membership_changes_total{type="HostAdded"} 3
+consistent_hashing_membership_changes_total{type="HostRemoved"} 1
+consistent_hashing_membership_changes_total{type="WeightChanged"} 1
+# HELP consistent_hashing_ring_version Ring version, bumped on every membership or weight change.
+# TYPE consistent_hashing_ring_version gauge
+consistent_hashing_ring_version 4
+# HELP consistent_hashing_spillovers_total Number of bounded-load lookups that skipped the saturated owner of the key.
+# TYPE consistent_hashing_spillovers_total counter
+consistent_hashing_spillovers_total 1
+# HELP consistent_hashing_vnodes Number of virtual nodes in the ring.
+# TYPE consistent_hashing_vnodes gauge
+consistent_hashing_vnodes 2
+`, ring.HostMaxLoad("host1"), ring.HostMaxLoad("host2"), ring.MaxLoad())
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectorOptions(t *testing.T) {
+	ring, _ := ch.NewWithConfig(ch.Config{ReplicationFactor: 3, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ring.Add(context.Background(), "host1")
+
+	// Namespace and const labels tell several rings apart in one registry.
+	reg := prometheus.NewRegistry()
+	Register(reg, ring, Options{Namespace: "cache", ConstLabels: prometheus.Labels{"ring": "sessions"}})
+	expected := `
+# HELP cache_hosts Number of hosts in the ring.
+# TYPE cache_hosts gauge
+cache_hosts{ring="sessions"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "cache_hosts"); err != nil {
+		t.Error(err)
+	}
+
+	// A failed registration leaves the registered collector observing the ring.
+	if _, err := Register(reg, ring, Options{Namespace: "cache", ConstLabels: prometheus.Labels{"ring": "sessions"}}); err == nil {
+		t.Errorf("Expected registering a duplicate collector to fail")
+	}
+	ring.Get(context.Background(), "key")
+	expected = `
+# HELP cache_lookups_total Number of successful lookups by method.
+# TYPE cache_lookups_total counter
+cache_lookups_total{method="Get",ring="sessions"} 1
+cache_lookups_total{method="GetLeast",ring="sessions"} 0
+cache_lookups_total{method="GetLeastN",ring="sessions"} 0
+cache_lookups_total{method="GetN",ring="sessions"} 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "cache_lookups_total"); err != nil {
+		t.Error(err)
+	}
+}
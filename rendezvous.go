@@ -0,0 +1,121 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Rendezvous places keys with Rendezvous, or highest random weight (HRW), hashing.
+// Every host scores every key and the host with the highest score wins, so removing a host
+// only moves its own keys. Lookups are O(hosts).
+type Rendezvous struct {
+	config Config
+	hosts  []string     // list of all hosts
+	seeds  []uint64     // hash of each host, combined with the key hash to score it
+	mu     sync.RWMutex // Mutex for synchronizing access
+}
+
+// NewRendezvous creates a new Rendezvous hashing balancer.
+func NewRendezvous(cfg Config) (*Rendezvous, error) {
+	cfg, err := withDefaults(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Rendezvous{config: cfg}, nil
+}
+
+// Add adds a host to the balancer.
+func (r *Rendezvous) Add(ctx context.Context, host string) error {
+	if host == "" {
+		return ErrInvalidHost
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if indexOf(r.hosts, host) >= 0 {
+		return fmt.Errorf("%w: %s", ErrHostExists, host)
+	}
+	r.hosts = append(r.hosts, host)
+	r.seeds = append(r.seeds, hashWith(r.config.HashFunction, host))
+	return nil
+}
+
+// Remove removes a host from the balancer.
+func (r *Rendezvous) Remove(ctx context.Context, host string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := indexOf(r.hosts, host)
+	if i < 0 {
+		return ErrHostNotFound
+	}
+	r.hosts = append(r.hosts[:i], r.hosts[i+1:]...)
+	r.seeds = append(r.seeds[:i], r.seeds[i+1:]...)
+	return nil
+}
+
+// Get retrieves the host with the highest score for the key.
+func (r *Rendezvous) Get(ctx context.Context, key string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.hosts) == 0 {
+		return "", ErrNoHost
+	}
+
+	h := hashWith(r.config.HashFunction, key)
+	best, bestScore := 0, uint64(0)
+	for i := range r.hosts {
+		if score := r.score(h, i); score > bestScore || i == 0 {
+			best, bestScore = i, score
+		}
+	}
+	return r.hosts[best], nil
+}
+
+// GetN retrieves the n hosts with the highest scores for the key, highest first.
+func (r *Rendezvous) GetN(ctx context.Context, key string, n int) ([]string, error) {
+	if n < 0 {
+		return nil, ErrInvalidReplicaCount
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.hosts) == 0 {
+		return nil, ErrNoHost
+	}
+	if n > len(r.hosts) {
+		return nil, ErrInsufficientHosts
+	}
+
+	h := hashWith(r.config.HashFunction, key)
+	order := make([]int, len(r.hosts))
+	scores := make([]uint64, len(r.hosts))
+	for i := range r.hosts {
+		order[i] = i
+		scores[i] = r.score(h, i)
+	}
+	sort.Slice(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	replicas := make([]string, n)
+	for i := range replicas {
+		replicas[i] = r.hosts[order[i]]
+	}
+	return replicas, nil
+}
+
+// Hosts returns the list of current hosts.
+func (r *Rendezvous) Hosts() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.hosts...)
+}
+
+// score combines the key hash with the hash of the i-th host.
+func (r *Rendezvous) score(keyHash uint64, i int) uint64 {
+	return mix64(keyHash ^ r.seeds[i])
+}
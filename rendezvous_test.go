@@ -0,0 +1,65 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestRendezvousMinimalRemoval(t *testing.T) {
+	r, _ := NewRendezvous(Config{})
+	assertMinimalRemoval(t, r)
+}
+
+func TestRendezvousGetNOrder(t *testing.T) {
+	r, _ := NewRendezvous(Config{})
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		r.Add(ctx, fmt.Sprintf("host%d", i))
+	}
+
+	// Replicas come in descending score order, with the host Get returns first.
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		h := hashWith(r.config.HashFunction, key)
+		replicas, err := r.GetN(ctx, key, 5)
+		if err != nil {
+			t.Fatalf("GetN failed: %v", err)
+		}
+		if owner, _ := r.Get(ctx, key); replicas[0] != owner {
+			t.Errorf("Key %s: expected %s first, got %v", key, owner, replicas)
+		}
+		for k := 1; k < len(replicas); k++ {
+			if r.score(h, indexOf(r.hosts, replicas[k-1])) < r.score(h, indexOf(r.hosts, replicas[k])) {
+				t.Errorf("Key %s: replicas %v are not ordered by score", key, replicas)
+				break
+			}
+		}
+
+		// Fewer replicas are a prefix of more replicas.
+		two, _ := r.GetN(ctx, key, 2)
+		if two[0] != replicas[0] || two[1] != replicas[1] {
+			t.Errorf("Key %s: expected %v to start with %v", key, replicas, two)
+		}
+	}
+}
+
+func TestRendezvousSpread(t *testing.T) {
+	r, _ := NewRendezvous(Config{})
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		r.Add(ctx, fmt.Sprintf("host%d", i))
+	}
+
+	// Every host wins a roughly equal share of the keys.
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		host, _ := r.Get(ctx, fmt.Sprintf("key%d", i))
+		counts[host]++
+	}
+	for host, count := range counts {
+		if count < 1600 || count > 2400 {
+			t.Errorf("Host %s owns %d of 10000 keys", host, count)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package consistent_hashing
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ring is an immutable snapshot of the hosts and virtual nodes of a ConsistentHashing.
+// Writers build a new ring and swap it in atomically, so readers only need a single atomic
+// load and never block. A ring must not be modified once it has been published.
+type ring struct {
+	config     *Config          // config the ring was built with
+	hashes     *hashPool        // reusable hash objects of the config's hash function
+	points     []uint64         // sorted virtual node positions
+	owners     []int32          // index into hosts of the owner of each position
+	hosts      []*Host          // hosts in the order they were added
+	weights    []int            // weight of each host
+	vnodes     [][]uint64       // virtual node positions of each host after collisions are resolved
+	index      map[string]int32 // map of host name to its index in hosts
+	weight     int64            // total weight across all hosts
+	healthy    int64            // total weight across healthy hosts, which carry the load
+	collisions int              // no of virtual nodes moved away from a colliding position
+}
+
+// vnodeOwner is a virtual node position and the index of the host owning it.
+type vnodeOwner struct {
+	point uint64
+	owner int32
+}
+
+// emptyRing returns a ring without hosts for the config.
+func emptyRing(cfg Config) *ring {
+	return &ring{
+		config: &cfg,
+		hashes: newHashPool(cfg.HashFunction),
+		index:  make(map[string]int32),
+	}
+}
+
+// newRing builds a ring from the hosts, their weights and their virtual node positions.
+// The config and hash pool are shared with base.
+func newRing(base *ring, hosts []*Host, weights []int, vnodes [][]uint64, collisions int) *ring {
+	// Collect every virtual node with its owner and sort them by position.
+	var pairs []vnodeOwner
+	for i := range hosts {
+		for _, point := range vnodes[i] {
+			pairs = append(pairs, vnodeOwner{point: point, owner: int32(i)})
+		}
+	}
+	// Positions are only shared in ketama mode, where the host with the smaller name wins.
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].point != pairs[j].point {
+			return pairs[i].point < pairs[j].point
+		}
+		return hosts[pairs[i].owner].Name < hosts[pairs[j].owner].Name
+	})
+
+	points := make([]uint64, len(pairs))
+	owners := make([]int32, len(pairs))
+	for i, p := range pairs {
+		points[i], owners[i] = p.point, p.owner
+	}
+	return newSortedRing(base, hosts, weights, vnodes, points, owners, collisions)
+}
+
+// newSortedRing builds a ring from the hosts, their weights and their virtual node positions,
+// given the positions of all virtual nodes already sorted along with their owners.
+// The config and hash pool are shared with base.
+func newSortedRing(base *ring, hosts []*Host, weights []int, vnodes [][]uint64, points []uint64, owners []int32, collisions int) *ring {
+	r := &ring{
+		config:     base.config,
+		hashes:     base.hashes,
+		points:     points,
+		owners:     owners,
+		hosts:      hosts,
+		weights:    weights,
+		vnodes:     vnodes,
+		index:      make(map[string]int32, len(hosts)),
+		collisions: collisions,
+	}
+	for i, h := range hosts {
+		r.index[h.Name] = int32(i)
+		r.weight += int64(weights[i])
+	}
+	r.healthy = r.healthyWeight()
+	return r
+}
+
+// withHealth returns a copy of the ring sharing its hosts and virtual nodes, with the total
+// weight of healthy hosts recomputed after the health of a host changed.
+func (r *ring) withHealth() *ring {
+	next := *r
+	next.healthy = r.healthyWeight()
+	return &next
+}
+
+// healthyWeight sums the weights of the hosts not marked unhealthy.
+func (r *ring) healthyWeight() int64 {
+	var weight int64
+	for i, h := range r.hosts {
+		if h.healthy() {
+			weight += int64(r.weights[i])
+		}
+	}
+	return weight
+}
+
+// mergePoints merges the positions in add, owned by the host at index owner, into a copy of the
+// sorted positions and their owners. This avoids sorting the whole ring when a host grows.
+func mergePoints(points []uint64, owners []int32, add []uint64, owner int32) ([]uint64, []int32) {
+	add = append([]uint64(nil), add...)
+	sort.Slice(add, func(i, j int) bool { return add[i] < add[j] })
+
+	mergedPoints := make([]uint64, 0, len(points)+len(add))
+	mergedOwners := make([]int32, 0, len(points)+len(add))
+	i, j := 0, 0
+	for i < len(points) || j < len(add) {
+		if j == len(add) || (i < len(points) && points[i] < add[j]) {
+			mergedPoints, mergedOwners = append(mergedPoints, points[i]), append(mergedOwners, owners[i])
+			i++
+		} else {
+			mergedPoints, mergedOwners = append(mergedPoints, add[j]), append(mergedOwners, owner)
+			j++
+		}
+	}
+	return mergedPoints, mergedOwners
+}
+
+// filterPoints returns a copy of the sorted positions and their owners, keeping only the virtual
+// nodes for which keep returns true. keep also returns the new owner index of a kept virtual node.
+func filterPoints(points []uint64, owners []int32, keep func(point uint64, owner int32) (int32, bool)) ([]uint64, []int32) {
+	keptPoints := make([]uint64, 0, len(points))
+	keptOwners := make([]int32, 0, len(points))
+	for i, point := range points {
+		if owner, ok := keep(point, owners[i]); ok {
+			keptPoints, keptOwners = append(keptPoints, point), append(keptOwners, owner)
+		}
+	}
+	return keptPoints, keptOwners
+}
+
+// search returns the index of the first virtual node at or after h, wrapping around the ring.
+// The ring must not be empty.
+func (r *ring) search(h uint64) int {
+	index := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	return index % len(r.points)
+}
+
+// owner returns the host owning the virtual node at index.
+func (r *ring) owner(index int) *Host {
+	return r.hosts[r.owners[index]]
+}
+
+// lookup returns the index of the named host, if it is in the ring.
+func (r *ring) lookup(host string) (int32, bool) {
+	i, ok := r.index[host]
+	return i, ok
+}
+
+// walkDistinct walks the ring clockwise starting at index and calls fn once for every
+// distinct host in the order it is first encountered. The walk stops when fn returns false.
+func (r *ring) walkDistinct(index int, fn func(i int32) bool) {
+	seen := make([]bool, len(r.hosts))
+	found := 0
+	for i := 0; i < len(r.points) && found < len(r.hosts); i++ {
+		owner := r.owners[(index+i)%len(r.points)]
+		if seen[owner] {
+			continue
+		}
+		seen[owner] = true
+		found++
+		if !fn(owner) {
+			return
+		}
+	}
+}
+
+// taken reports whether a virtual node of the ring sits at the position.
+func (r *ring) taken(point uint64) bool {
+	index := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= point })
+	return index < len(r.points) && r.points[index] == point
+}
+
+// free reports whether none of the positions is taken by a virtual node in the ring
+// or appears twice in points.
+func (r *ring) free(points []uint64) bool {
+	seen := make(map[uint64]struct{}, len(points))
+	for _, h := range points {
+		if r.taken(h) {
+			return false
+		}
+		if _, ok := seen[h]; ok {
+			return false
+		}
+		seen[h] = struct{}{}
+	}
+	return true
+}
+
+// hash generates a 64-bit hash value for a key using the ring's hash function.
+// The key is hashed in place without copying it to a byte slice.
+func (r *ring) hash(key string) (uint64, error) {
+	return r.hashBytes(stringBytes(key))
+}
+
+// hashBytes generates a 64-bit hash value for a key using the ring's hash function.
+func (r *ring) hashBytes(key []byte) (uint64, error) {
+	if r.config.Ketama {
+		return ketamaHash(key), nil
+	}
+	h, err := r.hashes.sum(key)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrHashFailed, err)
+	}
+	return h, nil
+}
+
+// vnodeKey returns the key hashed to place the i-th virtual node of a host.
+func (r *ring) vnodeKey(host string, i int) string {
+	return r.config.VNodeKey(host, i)
+}
+
+// probeKey returns the key hashed to re-place the i-th virtual node of a host after
+// its position collided salt times.
+func (r *ring) probeKey(host string, i, salt int) string {
+	return fmt.Sprintf("%s#%d", r.vnodeKey(host, i), salt)
+}
+
+// vnodeCount returns the number of virtual nodes for a host of the given weight.
+func (r *ring) vnodeCount(weight int) int {
+	return r.config.ReplicationFactor * weight
+}
+
+// hostPoints returns the positions of the virtual nodes of a host with the given weight before
+// collisions are resolved: its explicit tokens, or the hashes of its virtual node keys.
+func (r *ring) hostPoints(h *Host, weight int) ([]uint64, error) {
+	if h.Tokens != nil {
+		return h.Tokens, nil
+	}
+	return r.vnodePoints(h.Name, 0, r.vnodeCount(weight))
+}
+
+// vnodePoints computes the positions of the virtual nodes [from, to) of a host
+// before collisions are resolved.
+func (r *ring) vnodePoints(host string, from, to int) ([]uint64, error) {
+	points := make([]uint64, 0, to-from)
+	for i := from; i < to; i++ {
+		// Generate a hash value for the virtual node.
+		h, err := r.hash(r.vnodeKey(host, i))
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, h)
+	}
+	return points, nil
+}
+
+// placement is the position of every virtual node in the ring.
+type placement struct {
+	vnodes    map[string][]uint64 // positions of the virtual nodes of each host
+	relocated int                 // no of virtual nodes moved away from a colliding position
+}
+
+// place computes the position of every virtual node of the given hosts with the given weights.
+// Explicit tokens are placed first and never move, so a token taken twice is an error. Hashed hosts
+// are placed in name order and a virtual node that lands on a taken position is re-probed with a
+// salted key, so the result only depends on the set of hosts and not on the order they were added
+// in. It returns an error wrapping ErrVNodeCollision if a virtual node can not be placed.
+func (r *ring) place(hosts []*Host, weights []int) (*placement, error) {
+	owners := make(map[uint64]string)
+	p := &placement{vnodes: make(map[string][]uint64, len(hosts))}
+	for _, h := range hosts {
+		if h.Tokens == nil {
+			continue
+		}
+		for _, token := range h.Tokens {
+			if owner, ok := owners[token]; ok {
+				return nil, fmt.Errorf("%w: token %d of %s is taken by %s", ErrVNodeCollision, token, h.Name, owner)
+			}
+			owners[token] = h.Name
+		}
+		p.vnodes[h.Name] = h.Tokens
+	}
+	if r.config.Ketama {
+		placeKetama(p, hosts, weights)
+		return p, nil
+	}
+
+	order := make([]int, 0, len(hosts))
+	for i, h := range hosts {
+		if h.Tokens == nil {
+			order = append(order, i)
+		}
+	}
+	sort.Slice(order, func(i, j int) bool { return hosts[order[i]].Name < hosts[order[j]].Name })
+
+	for _, k := range order {
+		name := hosts[k].Name
+		points, err := r.vnodePoints(name, 0, r.vnodeCount(weights[k]))
+		if err != nil {
+			return nil, err
+		}
+		for i, point := range points {
+			// Re-probe with an increasing salt until a free position is found.
+			for salt := 1; ; salt++ {
+				if _, ok := owners[point]; !ok {
+					break
+				}
+				if salt > maxProbes {
+					return nil, fmt.Errorf("%w: no free position for %s", ErrVNodeCollision, r.vnodeKey(name, i))
+				}
+				if point, err = r.hash(r.probeKey(name, i, salt)); err != nil {
+					return nil, err
+				}
+			}
+			if point != points[i] {
+				p.relocated++
+			}
+			owners[point] = name
+			points[i] = point
+		}
+		p.vnodes[name] = points
+	}
+	return p, nil
+}
+
+// rebuild places the virtual nodes of the given hosts again and returns the resulting ring.
+func (r *ring) rebuild(hosts []*Host, weights []int) (*ring, error) {
+	p, err := r.place(hosts, weights)
+	if err != nil {
+		return nil, err
+	}
+	vnodes := make([][]uint64, len(hosts))
+	for i, h := range hosts {
+		vnodes[i] = p.vnodes[h.Name]
+	}
+	return newRing(r, hosts, weights, vnodes, p.relocated), nil
+}
+
+// withHost returns a copy of the ring with the host added. The new virtual nodes are inserted
+// directly if none of them collides, otherwise every host is placed again in canonical order.
+func (r *ring) withHost(h *Host, weight int) (*ring, error) {
+	// Ketama positions depend on every host, so the whole ring is recomputed.
+	if r.config.Ketama {
+		return r.rebuild(append(append([]*Host(nil), r.hosts...), h), append(append([]int(nil), r.weights...), weight))
+	}
+
+	points, err := r.hostPoints(h, weight)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := append(append(make([]*Host, 0, len(r.hosts)+1), r.hosts...), h)
+	weights := append(append(make([]int, 0, len(r.weights)+1), r.weights...), weight)
+	if r.collisions == 0 && r.free(points) {
+		vnodes := append(append(make([][]uint64, 0, len(r.vnodes)+1), r.vnodes...), points)
+		sorted, owners := mergePoints(r.points, r.owners, points, int32(len(r.hosts)))
+		return newSortedRing(r, hosts, weights, vnodes, sorted, owners, 0), nil
+	}
+	return r.rebuild(hosts, weights)
+}
+
+// withoutHost returns a copy of the ring without the host at index i. If collisions were
+// resolved, the remaining hosts are placed again so they can reclaim their original positions.
+func (r *ring) withoutHost(i int32) (*ring, error) {
+	hosts := make([]*Host, 0, len(r.hosts)-1)
+	weights := make([]int, 0, len(r.hosts)-1)
+	vnodes := make([][]uint64, 0, len(r.hosts)-1)
+	for k := range r.hosts {
+		if int32(k) == i {
+			continue
+		}
+		hosts = append(hosts, r.hosts[k])
+		weights = append(weights, r.weights[k])
+		vnodes = append(vnodes, r.vnodes[k])
+	}
+	if r.collisions == 0 && !r.config.Ketama {
+		// Drop the host's virtual nodes and shift the owner indices of the hosts after it.
+		sorted, owners := filterPoints(r.points, r.owners, func(_ uint64, owner int32) (int32, bool) {
+			if owner > i {
+				return owner - 1, true
+			}
+			return owner, owner != i
+		})
+		return newSortedRing(r, hosts, weights, vnodes, sorted, owners, 0), nil
+	}
+	return r.rebuild(hosts, weights)
+}
+
+// withWeight returns a copy of the ring with the weight of the host at index i changed.
+// Virtual nodes are only appended or trimmed at the end of the host's vnode sequence,
+// unless collisions force every host to be placed again.
+func (r *ring) withWeight(i int32, weight int) (*ring, error) {
+	weights := append([]int(nil), r.weights...)
+	weights[i] = weight
+
+	// Explicit tokens do not depend on the weight, which then only scales the host's max load.
+	if r.hosts[i].Tokens != nil {
+		return newSortedRing(r, r.hosts, weights, r.vnodes, r.points, r.owners, r.collisions), nil
+	}
+
+	if r.collisions == 0 && !r.config.Ketama {
+		vnodes := append([][]uint64(nil), r.vnodes...)
+		oldCount, newCount := len(r.vnodes[i]), r.vnodeCount(weight)
+
+		// Shrinking trims the tail of the host's virtual node sequence.
+		if newCount <= oldCount {
+			vnodes[i] = r.vnodes[i][:newCount:newCount]
+			trimmed := make(map[uint64]struct{}, oldCount-newCount)
+			for _, point := range r.vnodes[i][newCount:] {
+				trimmed[point] = struct{}{}
+			}
+			sorted, owners := filterPoints(r.points, r.owners, func(point uint64, owner int32) (int32, bool) {
+				_, ok := trimmed[point]
+				return owner, owner != i || !ok
+			})
+			return newSortedRing(r, r.hosts, weights, vnodes, sorted, owners, 0), nil
+		}
+
+		// Growing appends to the tail of the host's virtual node sequence.
+		points, err := r.vnodePoints(r.hosts[i].Name, oldCount, newCount)
+		if err != nil {
+			return nil, err
+		}
+		if r.free(points) {
+			vnodes[i] = append(append(make([]uint64, 0, newCount), r.vnodes[i]...), points...)
+			sorted, owners := mergePoints(r.points, r.owners, points, i)
+			return newSortedRing(r, r.hosts, weights, vnodes, sorted, owners, 0), nil
+		}
+	}
+
+	// Otherwise place every host again with the new weight.
+	return r.rebuild(r.hosts, weights)
+}
+
+// withHosts returns a ring with the given hosts and weights, built in one go. Without collisions
+// the virtual nodes of existing hosts are kept and only grown or trimmed to their new weight and
+// explicit tokens are used as given, otherwise, or if any of the new virtual nodes collides, every host is placed again.
+func (r *ring) withHosts(hosts []*Host, weights []int) (*ring, error) {
+	if r.collisions > 0 || r.config.Ketama {
+		return r.rebuild(hosts, weights)
+	}
+
+	vnodes := make([][]uint64, len(hosts))
+	taken := make(map[uint64]struct{}, len(r.points))
+	for i, h := range hosts {
+		var kept []uint64
+		if k, ok := r.lookup(h.Name); ok && r.hosts[k] == h {
+			kept = r.vnodes[k]
+		}
+		count := r.vnodeCount(weights[i])
+		if h.Tokens != nil {
+			vnodes[i] = h.Tokens
+		} else if count <= len(kept) {
+			vnodes[i] = kept[:count:count]
+		} else {
+			points, err := r.vnodePoints(h.Name, len(kept), count)
+			if err != nil {
+				return nil, err
+			}
+			vnodes[i] = append(append(make([]uint64, 0, count), kept...), points...)
+		}
+		for _, point := range vnodes[i] {
+			if _, ok := taken[point]; ok {
+				return r.rebuild(hosts, weights)
+			}
+			taken[point] = struct{}{}
+		}
+	}
+	return newRing(r, hosts, weights, vnodes, 0), nil
+}
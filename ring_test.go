@@ -0,0 +1,94 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"testing"
+)
+
+func TestRingIsImmutable(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.Add(ctx, "host1")
+
+	// A ring captured before a change keeps describing the old membership.
+	before := ch.ring.Load()
+	points := append([]uint64(nil), before.points...)
+	ch.Add(ctx, "host2")
+	ch.UpdateWeight(ctx, "host1", 3)
+	ch.Remove(ctx, "host1")
+
+	if len(before.hosts) != 1 || len(before.points) != len(points) {
+		t.Fatalf("Expected the old ring to keep 1 host and %d vnodes, got %d and %d", len(points), len(before.hosts), len(before.points))
+	}
+	for i := range points {
+		if before.points[i] != points[i] || before.owner(i).Name != "host1" {
+			t.Fatalf("Expected the old ring to be unchanged at vnode %d", i)
+		}
+	}
+
+	after := ch.ring.Load()
+	if len(after.hosts) != 1 || after.hosts[0].Name != "host2" || len(after.points) != 10 {
+		t.Errorf("Expected only host2 with 10 vnodes, got %v with %d vnodes", ch.Hosts(), len(after.points))
+	}
+}
+
+func TestRingSearchDoesNotAllocate(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 100, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	for i := 0; i < 10; i++ {
+		ch.Add(ctx, fmt.Sprintf("host%d", i))
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		r := ch.ring.Load()
+		_ = r.owner(r.search(12345)).Name
+	})
+	if allocs != 0 {
+		t.Errorf("Expected 0 allocations per lookup, got %f", allocs)
+	}
+}
+
+func TestConcurrentReadsDuringWrites(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.Add(ctx, "stable")
+
+	// Readers must always see a complete ring while writers add and remove hosts.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				r := ch.ring.Load()
+				if len(r.points) != len(r.owners) || len(r.points) != r.vnodeCount(int(r.weight)) {
+					t.Errorf("Observed a half-built ring with %d vnodes for weight %d", len(r.points), r.weight)
+					return
+				}
+				if _, err := ch.Get(ctx, "key"); err != nil {
+					t.Errorf("Expected no error, got %v", err)
+					return
+				}
+				ch.GetLeastN(ctx, "key", 1)
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		host := fmt.Sprintf("host%d", i%5)
+		if err := ch.Add(ctx, host); err != nil {
+			ch.Remove(ctx, host)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,248 @@
+package consistent_hashing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"sync/atomic"
+)
+
+// snapshotMagic prefixes binary ring snapshots.
+const snapshotMagic = "CHRS"
+
+// snapshotFormat is the current snapshot format version. Snapshots with a newer
+// format version are rejected with ErrSnapshotVersion.
+// Format 2 added the hash seed, format 3 the vnode naming scheme, format 4 explicit tokens.
+const snapshotFormat = 4
+
+// snapshot is the serialized state of a ring. The hash function is stored by its registered
+// name and the virtual node positions are stored to validate the restored ring.
+// The hash seed is stored in the clear so that every router restored from the snapshot agrees
+// on placement; snapshots of keyed rings must be kept as secret as the seed itself.
+type snapshot struct {
+	Format            int            `json:"format_version"`
+	RingVersion       uint64         `json:"ring_version"`
+	ReplicationFactor int            `json:"replication_factor"`
+	LoadFactor        float64        `json:"load_factor"`
+	HashName          string         `json:"hash_name"`
+	HashSeed          []byte         `json:"hash_seed,omitempty"`
+	Strategy          Strategy       `json:"strategy"`
+	Ketama            bool           `json:"ketama,omitempty"`
+	VNodeScheme       string         `json:"vnode_scheme"`
+	Hosts             []snapshotHost `json:"hosts"`
+}
+
+// snapshotHost is the serialized state of a host.
+type snapshotHost struct {
+	Name   string   `json:"name"`
+	Weight int      `json:"weight"`
+	Load   int64    `json:"load"`
+	Region string   `json:"region,omitempty"`
+	Zone   string   `json:"zone,omitempty"`
+	Rack   string   `json:"rack,omitempty"`
+	Points []uint64 `json:"points"`
+	Tokens bool     `json:"tokens,omitempty"` // the points are explicit tokens
+}
+
+// MarshalBinary encodes the ring state into a versioned binary snapshot.
+func (c *ConsistentHashing) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	binary.Write(&buf, binary.BigEndian, uint16(snapshotFormat))
+	if err := gob.NewEncoder(&buf).Encode(c.snapshot()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores the ring state from a binary snapshot created by MarshalBinary.
+// See restore for how the hash function is reconstructed and validated.
+func (c *ConsistentHashing) UnmarshalBinary(data []byte) error {
+	if len(data) < len(snapshotMagic)+2 || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return ErrInvalidSnapshot
+	}
+	format := binary.BigEndian.Uint16(data[len(snapshotMagic):])
+	if format > snapshotFormat {
+		return ErrSnapshotVersion
+	}
+
+	var s snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data[len(snapshotMagic)+2:])).Decode(&s); err != nil {
+		return ErrInvalidSnapshot
+	}
+	if s.Format != int(format) {
+		return ErrInvalidSnapshot
+	}
+	return c.restore(&s)
+}
+
+// MarshalJSON encodes the ring state into a versioned JSON snapshot.
+func (c *ConsistentHashing) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.snapshot())
+}
+
+// UnmarshalJSON restores the ring state from a JSON snapshot created by MarshalJSON.
+// See restore for how the hash function is reconstructed and validated.
+func (c *ConsistentHashing) UnmarshalJSON(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return ErrInvalidSnapshot
+	}
+	return c.restore(&s)
+}
+
+// snapshot captures the current ring state.
+func (c *ConsistentHashing) snapshot() *snapshot {
+	// Hold off writers so the ring version matches the captured ring.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := c.current()
+
+	s := &snapshot{
+		Format:            snapshotFormat,
+		RingVersion:       c.Version(),
+		ReplicationFactor: r.config.ReplicationFactor,
+		LoadFactor:        r.config.LoadFactor,
+		HashName:          r.config.HashName,
+		HashSeed:          r.config.HashSeed,
+		Strategy:          r.config.Strategy,
+		Ketama:            r.config.Ketama,
+		VNodeScheme:       r.config.VNodeScheme,
+		Hosts:             make([]snapshotHost, 0, len(r.hosts)),
+	}
+	for i, hostData := range r.hosts {
+		s.Hosts = append(s.Hosts, snapshotHost{
+			Name:   hostData.Name,
+			Weight: r.weights[i],
+			Load:   loadOf(hostData),
+			Region: hostData.Region,
+			Zone:   hostData.Zone,
+			Rack:   hostData.Rack,
+			Points: append([]uint64(nil), r.vnodes[i]...),
+			Tokens: hostData.Tokens != nil,
+		})
+	}
+	return s
+}
+
+// restore replaces the ring state with the snapshot. The hash function is looked up in the
+// registry by its stored name; snapshots of rings with an unregistered hash function can only
+// be restored into a ring already configured with that hash function. The virtual nodes are
+// placed again, including collision resolution, and compared with the stored positions before the
+// ring is changed, so a different hash function or vnode layout returns ErrSnapshotMismatch and
+// leaves the ring untouched. Hosts with explicit tokens are placed at their stored positions.
+// A snapshot without a format version, such as an empty JSON object, returns ErrInvalidSnapshot.
+// The ring version never goes back: it takes the stored version if that is newer, and is
+// bumped otherwise. Subscribers are notified with a RingRestored event.
+func (c *ConsistentHashing) restore(s *snapshot) error {
+	if s.Format < 1 {
+		return ErrInvalidSnapshot
+	}
+	if s.Format > snapshotFormat {
+		return ErrSnapshotVersion
+	}
+
+	// Rebuild the config, taking the hash function from the registry when possible.
+	// A zero ConsistentHashing that has not been used yet has no ring and restores with a zero config.
+	var cfg Config
+	if r := c.ring.Load(); r != nil {
+		cfg = *r.config
+	}
+	cfg.ReplicationFactor = s.ReplicationFactor
+	cfg.LoadFactor = s.LoadFactor
+	cfg.Strategy = s.Strategy
+	cfg.Ketama = s.Ketama
+	cfg.HashName = s.HashName
+	cfg.HashSeed = s.HashSeed
+	if s.HashName != "" {
+		cfg.HashFunction = nil
+	} else if cfg.HashFunction == nil {
+		return ErrUnknownHash
+	}
+
+	// Snapshots from before format 3 were taken with the legacy vnode naming scheme.
+	cfg.VNodeScheme = s.VNodeScheme
+	if s.Format < 3 {
+		cfg.VNodeScheme = LegacyVNodeScheme
+	}
+	if cfg.VNodeScheme != "" {
+		cfg.VNodeKey = nil
+	} else if cfg.VNodeKey == nil {
+		return ErrUnknownVNodeScheme
+	}
+	cfg, err := withDefaults(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Place the stored hosts with the restored config and validate the stored
+	// virtual node positions against the recomputed ones.
+	hosts := make([]*Host, 0, len(s.Hosts))
+	weights := make([]int, 0, len(s.Hosts))
+	names := make(map[string]struct{}, len(s.Hosts))
+	var totalLoad int64
+	for _, host := range s.Hosts {
+		if _, ok := names[host.Name]; ok || host.Name == "" || host.Weight <= 0 || host.Load < 0 {
+			return ErrInvalidSnapshot
+		}
+		names[host.Name] = struct{}{}
+		var tokens []uint64
+		if host.Tokens {
+			if len(host.Points) == 0 {
+				return ErrInvalidSnapshot
+			}
+			tokens = append([]uint64(nil), host.Points...)
+		}
+		hosts = append(hosts, &Host{
+			Name:   host.Name,
+			Load:   host.Load,
+			Region: host.Region,
+			Zone:   host.Zone,
+			Rack:   host.Rack,
+			Tokens: tokens,
+		})
+		weights = append(weights, host.Weight)
+		totalLoad += host.Load
+	}
+	next, err := emptyRing(cfg).rebuild(hosts, weights)
+	if err != nil {
+		return err
+	}
+	for i, host := range s.Hosts {
+		points := next.vnodes[i]
+		if len(points) != len(host.Points) {
+			return ErrSnapshotMismatch
+		}
+		for k := range points {
+			if points[k] != host.Points[k] {
+				return ErrSnapshotMismatch
+			}
+		}
+	}
+
+	// Publish the restored ring under the writer lock.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Retire the replaced hosts, so their loads leave the total load and load changes still
+	// holding them fail, then count the restored loads in.
+	old := c.ring.Load()
+	c.ring.Store(next)
+	if old != nil {
+		for _, h := range old.hosts {
+			c.retire(h)
+		}
+	}
+	atomic.AddInt64(&c.totalLoad, totalLoad)
+
+	// Keep the version increasing, so a restored ring is never mistaken for an older one.
+	version := s.RingVersion
+	if current := c.Version(); version <= current {
+		version = current + 1
+	}
+	atomic.StoreUint64(&c.version, version)
+	c.publish(RingEvent{Type: EventRingRestored, Version: version})
+
+	return nil
+}
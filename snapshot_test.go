@@ -0,0 +1,160 @@
+package consistent_hashing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"testing"
+)
+
+func newSnapshotRing(t *testing.T) *ConsistentHashing {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 20, LoadFactor: 1.5, Strategy: StrategyLeastLoaded})
+	ctx := context.Background()
+	ch.AddWithOptions(ctx, "host1", HostOptions{Weight: 2, Zone: "a"})
+	ch.AddWithOptions(ctx, "host2", HostOptions{Zone: "b"})
+	ch.Add(ctx, "host3")
+	ch.UpdateLoad(ctx, "host2", 7)
+	return ch
+}
+
+func assertSameRing(t *testing.T, want, got *ConsistentHashing) {
+	ctx := context.Background()
+	if got.Version() != want.Version() {
+		t.Errorf("Expected version %d, got %d", want.Version(), got.Version())
+	}
+	wantCfg, gotCfg := want.ring.Load().config, got.ring.Load().config
+	if gotCfg.LoadFactor != wantCfg.LoadFactor || gotCfg.Strategy != wantCfg.Strategy {
+		t.Errorf("Expected config %+v, got %+v", *wantCfg, *gotCfg)
+	}
+	if fmt.Sprint(got.GetLoads()) != fmt.Sprint(want.GetLoads()) {
+		t.Errorf("Expected loads %v, got %v", want.GetLoads(), got.GetLoads())
+	}
+	if got.MaxLoad() != want.MaxLoad() {
+		t.Errorf("Expected max load %d, got %d", want.MaxLoad(), got.MaxLoad())
+	}
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("key%d", i)
+		a, _ := want.Get(ctx, key)
+		b, _ := got.Get(ctx, key)
+		if a != b {
+			t.Fatalf("Key %s: expected %s, got %s", key, a, b)
+		}
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	ch := newSnapshotRing(t)
+	data, err := ch.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	var restored ConsistentHashing
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	assertSameRing(t, ch, &restored)
+
+	if err := restored.UnmarshalBinary([]byte("nope")); err != ErrInvalidSnapshot {
+		t.Errorf("Expected ErrInvalidSnapshot, got %v", err)
+	}
+	data[len(snapshotMagic)+1] = snapshotFormat + 1
+	if err := restored.UnmarshalBinary(data); err != ErrSnapshotVersion {
+		t.Errorf("Expected ErrSnapshotVersion, got %v", err)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	ch := newSnapshotRing(t)
+	data, err := json.Marshal(ch)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+
+	restored, _ := NewWithConfig(Config{})
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	assertSameRing(t, ch, restored)
+}
+
+func TestRestoreMismatch(t *testing.T) {
+	custom := func() hash.Hash64 { return fnv.New64() }
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 5, HashFunction: custom})
+	ch.Add(context.Background(), "host1")
+	data, _ := ch.MarshalBinary()
+
+	// An unregistered hash function can not be reconstructed from the snapshot.
+	var empty ConsistentHashing
+	if err := empty.UnmarshalBinary(data); err != ErrUnknownHash {
+		t.Errorf("Expected ErrUnknownHash, got %v", err)
+	}
+
+	// Restoring with a different hash function is detected.
+	other, _ := NewWithConfig(Config{HashFunction: func() hash.Hash64 { return fnv.New64a() }})
+	if err := other.UnmarshalBinary(data); err != ErrSnapshotMismatch {
+		t.Errorf("Expected ErrSnapshotMismatch, got %v", err)
+	}
+
+	// Restoring with the same hash function works.
+	same, _ := NewWithConfig(Config{HashFunction: custom})
+	if err := same.UnmarshalBinary(data); err != nil {
+		t.Errorf("UnmarshalBinary failed: %v", err)
+	}
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	ch := newSnapshotRing(t)
+	version := ch.Version()
+
+	// Snapshots without a format version never wipe the ring.
+	for _, data := range []string{"{}", "null", `{"hosts": []}`} {
+		if err := ch.UnmarshalJSON([]byte(data)); err != ErrInvalidSnapshot {
+			t.Errorf("%s: expected ErrInvalidSnapshot, got %v", data, err)
+		}
+	}
+	if len(ch.Hosts()) != 3 || ch.Version() != version {
+		t.Errorf("Expected the ring to be untouched, got hosts %v at version %d", ch.Hosts(), ch.Version())
+	}
+
+	// The binary header has to match the encoded format version.
+	data, _ := ch.MarshalBinary()
+	data[len(snapshotMagic)+1] = snapshotFormat - 1
+	if err := ch.UnmarshalBinary(data); err != ErrInvalidSnapshot {
+		t.Errorf("Expected ErrInvalidSnapshot, got %v", err)
+	}
+}
+
+func TestRestoreVersion(t *testing.T) {
+	ctx := context.Background()
+	ch := newSnapshotRing(t)
+	data, _ := ch.MarshalJSON()
+	ch.Add(ctx, "host4")
+	ch.Remove(ctx, "host4")
+	version := ch.Version()
+
+	// Restoring an older snapshot bumps the version past the current one and is published.
+	events := ch.Subscribe(ctx)
+	if err := ch.UnmarshalJSON(data); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	if ch.Version() != version+1 {
+		t.Errorf("Expected version %d, got %d", version+1, ch.Version())
+	}
+	if ev := <-events; ev.Type != EventRingRestored || ev.Version != version+1 {
+		t.Errorf("Expected a RingRestored event at version %d, got %+v", version+1, ev)
+	}
+
+	// A newer snapshot keeps its own version.
+	newer := newSnapshotRing(t)
+	for i := 0; i < 10; i++ {
+		newer.UpdateWeight(ctx, "host3", i+1)
+	}
+	data, _ = newer.MarshalJSON()
+	ch.UnmarshalJSON(data)
+	if ch.Version() != newer.Version() {
+		t.Errorf("Expected version %d, got %d", newer.Version(), ch.Version())
+	}
+}
@@ -0,0 +1,86 @@
+package consistent_hashing
+
+import (
+	"math"
+)
+
+// RingStats describes the current shape and balance of the ring.
+type RingStats struct {
+	Hosts      int         // no of hosts in the ring
+	VNodes     int         // no of virtual nodes in the ring
+	Collisions int         // no of virtual nodes re-probed away from a colliding position
+	PerHost    []HostStats // statistics of every host, in the order the hosts were added
+
+	// Balance of the ring, computed over the owned fraction of every host divided by its
+	// expected fraction. A perfectly balanced ring has a StdDev of 0 and a MaxOverMean of 1,
+	// an empty ring has both 0.
+	StdDev      float64 // standard deviation of owned over expected fraction
+	MaxOverMean float64 // largest owned over expected fraction divided by their mean
+}
+
+// HostStats describes the share of the ring and the load of a single host.
+type HostStats struct {
+	Name     string  // host name
+	Weight   int     // weight of the host
+	VNodes   int     // no of virtual nodes of the host
+	Owned    float64 // fraction of the hash space owned by the host
+	Expected float64 // fraction of the hash space the host should own given its weight
+	Load     int64   // current load on the host
+	MaxLoad  int64   // maximum allowed load on the host
+	Healthy  bool    // whether the host is healthy, see SetHealthy
+}
+
+// Stats returns statistics about the current ring. The owned fractions are computed from the
+// gaps between virtual nodes, so they describe how evenly keys spread over the hosts for the
+// current ReplicationFactor independently of the loads tracked with IncreaseLoad.
+func (c *ConsistentHashing) Stats() RingStats {
+	r := c.current()
+
+	stats := RingStats{
+		Hosts:      len(r.hosts),
+		VNodes:     len(r.points),
+		Collisions: r.collisions,
+		PerHost:    make([]HostStats, len(r.hosts)),
+	}
+	for i, h := range r.hosts {
+		stats.PerHost[i] = HostStats{
+			Name:     h.Name,
+			Weight:   r.weights[i],
+			VNodes:   len(r.vnodes[i]),
+			Expected: float64(r.weights[i]) / float64(r.weight),
+			Load:     loadOf(h),
+			MaxLoad:  c.maxLoadFor(r, r.weights[i]),
+			Healthy:  h.healthy(),
+		}
+	}
+
+	// Every virtual node owns the keys between the previous virtual node and itself.
+	for i, point := range r.points {
+		prev := r.points[(i+len(r.points)-1)%len(r.points)]
+		// Positions shared in ketama mode own nothing, except when only one virtual node exists.
+		if prev == point && len(r.points) > 1 {
+			continue
+		}
+		stats.PerHost[r.owners[i]].Owned += KeyRange{Start: prev, End: point}.Fraction()
+	}
+
+	// Compare the owned with the expected fractions.
+	if len(r.points) == 0 {
+		return stats
+	}
+	var sum, max float64
+	for _, h := range stats.PerHost {
+		ratio := h.Owned / h.Expected
+		sum += ratio
+		max = math.Max(max, ratio)
+	}
+	mean := sum / float64(len(stats.PerHost))
+	var variance float64
+	for _, h := range stats.PerHost {
+		d := h.Owned/h.Expected - mean
+		variance += d * d
+	}
+	stats.StdDev = math.Sqrt(variance / float64(len(stats.PerHost)))
+	stats.MaxOverMean = max / mean
+	return stats
+}
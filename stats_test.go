@@ -0,0 +1,181 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math"
+	"testing"
+)
+
+// collidingHash is fnv64a, except that the given keys all hash to the same value.
+type collidingHash struct {
+	hash.Hash64
+	keys map[string]bool
+	buf  []byte
+}
+
+func (h *collidingHash) Write(p []byte) (int, error) {
+	h.buf = append(h.buf, p...)
+	return h.Hash64.Write(p)
+}
+
+func (h *collidingHash) Reset() {
+	h.buf = h.buf[:0]
+	h.Hash64.Reset()
+}
+
+func (h *collidingHash) Sum64() uint64 {
+	if h.keys[string(h.buf)] {
+		return 7
+	}
+	return h.Hash64.Sum64()
+}
+
+// ownerAt returns the owner of the virtual node at the position, or an empty string if there is none.
+func ownerAt(ch *ConsistentHashing, point uint64) string {
+	r := ch.ring.Load()
+	if !r.taken(point) {
+		return ""
+	}
+	return r.owner(r.search(point)).Name
+}
+
+func newCollidingRing(keys ...string) *ConsistentHashing {
+	set := make(map[string]bool)
+	for _, key := range keys {
+		set[key] = true
+	}
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 2, HashFunction: func() hash.Hash64 {
+		return &collidingHash{Hash64: fnv.New64a(), keys: set}
+	}})
+	return ch
+}
+
+func TestCollisionResolution(t *testing.T) {
+	ctx := context.Background()
+
+	// vnode 0 of host1 and host2 both hash to 7.
+	a := newCollidingRing("5:host1:0", "5:host2:0")
+	a.Add(ctx, "host1")
+	a.Add(ctx, "host2")
+	a.Add(ctx, "host3")
+	b := newCollidingRing("5:host1:0", "5:host2:0")
+	b.Add(ctx, "host3")
+	b.Add(ctx, "host2")
+	b.Add(ctx, "host1")
+
+	if stats := a.Stats(); stats.Collisions != 1 || stats.VNodes != 6 {
+		t.Errorf("Expected 6 vnodes and 1 collision, got %+v", stats)
+	}
+	if host := ownerAt(a, 7); host != "host1" {
+		t.Errorf("Expected host1 to own the colliding position, got %v", host)
+	}
+
+	// The order of Add calls does not affect ownership.
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		x, _ := a.Get(ctx, key)
+		y, _ := b.Get(ctx, key)
+		if x != y {
+			t.Fatalf("Key %s: %s when added in order, %s when added in reverse", key, x, y)
+		}
+	}
+
+	// Removing the winner hands the colliding position back to host2.
+	a.Remove(ctx, "host1")
+	if host := ownerAt(a, 7); host != "host2" {
+		t.Errorf("Expected host2 to reclaim the colliding position, got %v", host)
+	}
+	if stats := a.Stats(); stats.Collisions != 0 || stats.VNodes != 4 {
+		t.Errorf("Expected 4 vnodes and no collisions, got %+v", stats)
+	}
+}
+
+func TestCollisionResolutionUpdateWeight(t *testing.T) {
+	ctx := context.Background()
+
+	// vnode 2 of host1 collides with vnode 0 of host2 once host1 grows.
+	ch := newCollidingRing("5:host1:2", "5:host2:0")
+	ch.Add(ctx, "host2")
+	ch.Add(ctx, "host1")
+	ch.UpdateWeight(ctx, "host1", 2)
+	if stats := ch.Stats(); stats.Collisions != 1 || stats.VNodes != 6 {
+		t.Errorf("Expected 6 vnodes and 1 collision, got %+v", stats)
+	}
+	if host := ownerAt(ch, 7); host != "host1" {
+		t.Errorf("Expected host1 to own the colliding position, got %v", host)
+	}
+	ch.UpdateWeight(ctx, "host1", 1)
+	if host := ownerAt(ch, 7); host != "host2" {
+		t.Errorf("Expected host2 to reclaim the colliding position, got %v", host)
+	}
+}
+
+func TestStatsOwnership(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	if stats := ch.Stats(); len(stats.PerHost) != 0 || stats.StdDev != 0 || stats.MaxOverMean != 0 {
+		t.Errorf("Expected empty stats, got %+v", stats)
+	}
+
+	// A single host owns the whole ring.
+	ch.AddWithTokens(ctx, "a", []uint64{0, 1 << 63})
+	if stats := ch.Stats(); stats.PerHost[0].Owned != 1 || stats.MaxOverMean != 1 || stats.StdDev != 0 {
+		t.Errorf("Expected a to own the whole ring, got %+v", stats)
+	}
+
+	// a owns (2^63, 0] and (2^62, 2^63], b owns (0, 2^62].
+	ch.AddWithTokens(ctx, "b", []uint64{1 << 62})
+	ch.IncreaseLoad(ctx, "a")
+	ch.IncreaseLoad(ctx, "a")
+	stats := ch.Stats()
+	want := []HostStats{
+		{Name: "a", Weight: 1, VNodes: 2, Owned: 0.75, Expected: 0.5, Load: 2, MaxLoad: ch.HostMaxLoad("a"), Healthy: true},
+		{Name: "b", Weight: 1, VNodes: 1, Owned: 0.25, Expected: 0.5, Load: 0, MaxLoad: ch.HostMaxLoad("b"), Healthy: true},
+	}
+	for i := range want {
+		if stats.PerHost[i] != want[i] {
+			t.Errorf("Host %d: expected %+v, got %+v", i, want[i], stats.PerHost[i])
+		}
+	}
+	if stats.StdDev != 0.5 || stats.MaxOverMean != 1.5 {
+		t.Errorf("Expected stddev 0.5 and max over mean 1.5, got %f and %f", stats.StdDev, stats.MaxOverMean)
+	}
+
+	// Weights set the expected fraction, so b at weight 3 is now underweight.
+	ch.UpdateWeight(ctx, "b", 3)
+	if stats := ch.Stats(); stats.PerHost[1].Expected != 0.75 || stats.PerHost[0].Owned/stats.PerHost[0].Expected != 3 {
+		t.Errorf("Expected b to expect 0.75 and a to own 3 times its share, got %+v", stats.PerHost)
+	}
+}
+
+func TestStatsReplicationFactor(t *testing.T) {
+	ctx := context.Background()
+
+	// More virtual nodes spread the keys more evenly.
+	var last float64
+	for _, rf := range []int{1, 10, 100, 1000} {
+		ch, _ := NewWithConfig(Config{ReplicationFactor: rf, LoadFactor: 1.25, HashName: "xxhash64"})
+		for i := 0; i < 10; i++ {
+			ch.Add(ctx, fmt.Sprintf("host%d", i))
+		}
+		stats := ch.Stats()
+
+		var owned float64
+		for _, h := range stats.PerHost {
+			if h.VNodes != rf {
+				t.Errorf("Expected %d vnodes for %s, got %d", rf, h.Name, h.VNodes)
+			}
+			owned += h.Owned
+		}
+		if math.Abs(owned-1) > 1e-9 {
+			t.Errorf("Expected owned fractions to add up to 1, got %f", owned)
+		}
+		if last != 0 && stats.StdDev >= last {
+			t.Errorf("Expected stddev to drop with replication factor %d, got %f after %f", rf, stats.StdDev, last)
+		}
+		last = stats.StdDev
+	}
+}
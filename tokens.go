@@ -0,0 +1,130 @@
+package consistent_hashing
+
+import (
+	"container/heap"
+	"context"
+	"math/bits"
+	"sort"
+)
+
+// AddWithTokens adds a host whose virtual nodes sit exactly at the given tokens instead of the
+// hashes of its name, like a Cassandra node with an explicit initial_token list. The host has a
+// weight of 1, which only scales its max load. Hashed virtual nodes that land on a token are
+// re-probed, but a token already held by another tokenized host returns an error wrapping
+// ErrVNodeCollision. It returns ErrInvalidTokens if no tokens are given.
+// See EvenTokens and BalancedTokens for generating tokens.
+func (c *ConsistentHashing) AddWithTokens(ctx context.Context, host string, tokens []uint64) error {
+	if len(tokens) == 0 {
+		return ErrInvalidTokens
+	}
+	return c.AddWithOptions(ctx, host, HostOptions{Tokens: tokens})
+}
+
+// Tokens returns the sorted positions of the virtual nodes of the host, whether they are
+// explicit tokens or hashed from its name.
+func (c *ConsistentHashing) Tokens(host string) ([]uint64, error) {
+	r := c.current()
+	i, ok := r.lookup(host)
+	if !ok {
+		return nil, ErrHostNotFound
+	}
+	tokens := append([]uint64(nil), r.vnodes[i]...)
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+	return tokens, nil
+}
+
+// EvenTokens returns n tokens spaced evenly around the ring, starting at offset.
+// Giving the j-th of k hosts EvenTokens(n, j * (2^64 / (n * k))) interleaves their tokens
+// so every host owns an equal share of the ring.
+func EvenTokens(n int, offset uint64) []uint64 {
+	if n <= 0 {
+		return nil
+	}
+	tokens := make([]uint64, n)
+	for i := range tokens {
+		// floor(i * 2^64 / n), computed without overflowing.
+		step, _ := bits.Div64(uint64(i), 0, uint64(n))
+		tokens[i] = offset + step
+	}
+	return tokens
+}
+
+// BalancedTokens returns n tokens for a new host that take from the most loaded parts of the
+// current ring: every token splits the largest remaining gap between two virtual nodes in half,
+// so the new host takes at most half of any existing range. On an empty ring they are the same
+// as EvenTokens(n, 0).
+func (c *ConsistentHashing) BalancedTokens(n int) []uint64 {
+	r := c.current()
+	if len(r.points) == 0 {
+		return EvenTokens(n, 0)
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	// Collect the gap after every virtual node. A ring with a single virtual node has one gap
+	// covering the whole ring, which is recorded with size 0. Positions shared in ketama mode
+	// leave empty gaps, which are skipped.
+	gaps := make(tokenGaps, 0, len(r.points))
+	for i, point := range r.points {
+		size := r.points[(i+1)%len(r.points)] - point
+		if size == 0 && len(r.points) > 1 {
+			continue
+		}
+		gaps = append(gaps, tokenGap{start: point, size: size})
+	}
+	heap.Init(&gaps)
+
+	// Split the largest gap until n tokens are found. Gaps of size 1 can not be split.
+	tokens := make([]uint64, 0, n)
+	for len(tokens) < n && gaps[0].size != 1 {
+		gap := heap.Pop(&gaps).(tokenGap)
+		half := gap.size / 2
+		if gap.size == 0 {
+			half = 1 << 63
+		}
+		tokens = append(tokens, gap.start+half)
+		heap.Push(&gaps, tokenGap{start: gap.start, size: half})
+		heap.Push(&gaps, tokenGap{start: gap.start + half, size: gap.size - half})
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+	return tokens
+}
+
+// copyTokens returns a copy of the tokens, or nil if there are none.
+func copyTokens(tokens []uint64) []uint64 {
+	if len(tokens) == 0 {
+		return nil
+	}
+	return append([]uint64(nil), tokens...)
+}
+
+// tokenGap is the range of the ring between a virtual node and the next one.
+type tokenGap struct {
+	start uint64 // position of the virtual node the gap starts at
+	size  uint64 // distance to the next virtual node, 0 for the whole ring
+}
+
+// tokenGaps is a heap of gaps, largest first and ties broken by position.
+type tokenGaps []tokenGap
+
+func (g tokenGaps) Len() int { return len(g) }
+
+func (g tokenGaps) Less(i, j int) bool {
+	// Subtracting 1 maps the whole ring, size 0, to the largest size.
+	if g[i].size-1 != g[j].size-1 {
+		return g[i].size-1 > g[j].size-1
+	}
+	return g[i].start < g[j].start
+}
+
+func (g tokenGaps) Swap(i, j int) { g[i], g[j] = g[j], g[i] }
+
+func (g *tokenGaps) Push(x any) { *g = append(*g, x.(tokenGap)) }
+
+func (g *tokenGaps) Pop() any {
+	old := *g
+	x := old[len(old)-1]
+	*g = old[:len(old)-1]
+	return x
+}
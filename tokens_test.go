@@ -0,0 +1,246 @@
+package consistent_hashing
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"testing"
+)
+
+func TestAddWithTokens(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+
+	// Two hosts splitting the ring in quarters.
+	if err := ch.AddWithTokens(ctx, "a", []uint64{0, 1 << 63}); err != nil {
+		t.Fatalf("AddWithTokens failed: %v", err)
+	}
+	if err := ch.AddWithTokens(ctx, "b", []uint64{1 << 62, 3 << 62}); err != nil {
+		t.Fatalf("AddWithTokens failed: %v", err)
+	}
+
+	// A key belongs to the first token at or after its hash.
+	tests := []struct {
+		hash uint64
+		want string
+	}{
+		{0, "a"},
+		{1, "b"},
+		{1 << 62, "b"},
+		{1<<62 + 1, "a"},
+		{3 << 62, "b"},
+		{math.MaxUint64, "a"},
+	}
+	for _, tt := range tests {
+		if got, _ := ch.GetHash(ctx, tt.hash); got != tt.want {
+			t.Errorf("Hash %d: expected %s, got %s", tt.hash, tt.want, got)
+		}
+	}
+
+	// Tokens are reported sorted.
+	if tokens, _ := ch.Tokens("a"); len(tokens) != 2 || tokens[0] != 0 || tokens[1] != 1<<63 {
+		t.Errorf("Expected tokens [0 %d], got %v", uint64(1<<63), tokens)
+	}
+
+	// Changing the weight of a tokenized host only changes its max load.
+	ch.UpdateWeight(ctx, "a", 3)
+	if tokens, _ := ch.Tokens("a"); len(tokens) != 2 {
+		t.Errorf("Expected weight change to keep 2 tokens, got %v", tokens)
+	}
+	if r := ch.ring.Load(); r.weights[0] != 3 || r.weight != 4 {
+		t.Errorf("Expected weights [3 1], got %v", r.weights)
+	}
+
+	// A hashed host coexists with tokenized hosts.
+	ch.Add(ctx, "c")
+	if tokens, _ := ch.Tokens("c"); len(tokens) != 10 {
+		t.Errorf("Expected 10 hashed vnodes for c, got %d", len(tokens))
+	}
+
+	// Removing a host hands its tokens to the next host clockwise.
+	ch.Remove(ctx, "b")
+	if got, _ := ch.GetHash(ctx, 1<<62); got == "b" {
+		t.Errorf("Expected removed host b to own no keys")
+	}
+	if _, err := ch.Tokens("b"); err != ErrHostNotFound {
+		t.Errorf("Expected ErrHostNotFound, got %v", err)
+	}
+}
+
+func TestAddWithTokensErrors(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.AddWithTokens(ctx, "a", []uint64{100, 200})
+
+	if err := ch.AddWithTokens(ctx, "b", nil); err != ErrInvalidTokens {
+		t.Errorf("Expected ErrInvalidTokens, got %v", err)
+	}
+	if err := ch.AddWithTokens(ctx, "b", []uint64{200, 300}); !errors.Is(err, ErrVNodeCollision) {
+		t.Errorf("Expected ErrVNodeCollision, got %v", err)
+	}
+	if err := ch.AddWithTokens(ctx, "b", []uint64{300, 300}); !errors.Is(err, ErrVNodeCollision) {
+		t.Errorf("Expected ErrVNodeCollision for a repeated token, got %v", err)
+	}
+	if hosts := ch.Hosts(); len(hosts) != 1 {
+		t.Errorf("Expected failed adds to leave the ring unchanged, got %v", hosts)
+	}
+
+	// Batches reject colliding tokens as a whole.
+	err := ch.Apply(ctx, []Change{
+		{Op: ChangeAdd, Host: "b", Options: HostOptions{Tokens: []uint64{300}}},
+		{Op: ChangeAdd, Host: "c", Options: HostOptions{Tokens: []uint64{300}}},
+	})
+	if !errors.Is(err, ErrVNodeCollision) {
+		t.Errorf("Expected ErrVNodeCollision, got %v", err)
+	}
+}
+
+func TestHashedVNodeOnToken(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.Add(ctx, "a")
+	taken, _ := ch.Tokens("a")
+
+	// A token on a hashed virtual node wins, the hashed virtual node is re-probed.
+	if err := ch.AddWithTokens(ctx, "b", []uint64{taken[0]}); err != nil {
+		t.Fatalf("AddWithTokens failed: %v", err)
+	}
+	if got, _ := ch.GetHash(ctx, taken[0]); got != "b" {
+		t.Errorf("Expected b to own its token, got %s", got)
+	}
+	if tokens, _ := ch.Tokens("a"); len(tokens) != 10 {
+		t.Errorf("Expected a to keep 10 vnodes, got %d", len(tokens))
+	}
+	if stats := ch.Stats(); stats.Collisions != 1 {
+		t.Errorf("Expected 1 collision, got %d", stats.Collisions)
+	}
+}
+
+func TestEvenTokens(t *testing.T) {
+	tokens := EvenTokens(4, 10)
+	want := []uint64{10, 1<<62 + 10, 1<<63 + 10, 3<<62 + 10}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("Token %d: expected %d, got %d", i, want[i], tokens[i])
+		}
+	}
+	if tokens := EvenTokens(3, 0); tokens[1] != math.MaxUint64/3 || tokens[2] != math.MaxUint64/3*2 {
+		t.Errorf("Expected thirds of the ring, got %v", tokens)
+	}
+	if tokens := EvenTokens(0, 0); tokens != nil {
+		t.Errorf("Expected no tokens, got %v", tokens)
+	}
+}
+
+func TestBalancedTokens(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+
+	// An empty ring gets evenly spaced tokens.
+	if tokens := ch.BalancedTokens(2); len(tokens) != 2 || tokens[0] != 0 || tokens[1] != 1<<63 {
+		t.Errorf("Expected [0 %d], got %v", uint64(1<<63), tokens)
+	}
+
+	// A single token leaves the whole ring as one gap.
+	ch.AddWithTokens(ctx, "a", []uint64{0})
+	if tokens := ch.BalancedTokens(1); len(tokens) != 1 || tokens[0] != 1<<63 {
+		t.Errorf("Expected [%d], got %v", uint64(1<<63), tokens)
+	}
+
+	// Every token splits the largest remaining gap.
+	ch.AddWithTokens(ctx, "b", []uint64{1 << 63})
+	tokens := ch.BalancedTokens(3)
+	want := []uint64{1 << 62, 1<<63 + 1<<62, 1 << 61}
+	if len(tokens) != 3 {
+		t.Fatalf("Expected 3 tokens, got %v", tokens)
+	}
+	for i, token := range []uint64{want[2], want[0], want[1]} {
+		if tokens[i] != token {
+			t.Errorf("Token %d: expected %d, got %d", i, token, tokens[i])
+		}
+	}
+
+	// Balanced tokens for a hashed ring are free and take at least a fair share of the keys,
+	// but no more than half, since every token takes half of a gap.
+	hashed, _ := NewWithConfig(Config{ReplicationFactor: 50, LoadFactor: 1.25, HashName: "xxhash64"})
+	hashed.AddHosts(ctx, "h1", "h2", "h3")
+	tokens = hashed.BalancedTokens(50)
+	if err := hashed.AddWithTokens(ctx, "h4", tokens); err != nil {
+		t.Fatalf("AddWithTokens failed: %v", err)
+	}
+	if stats := hashed.Stats(); stats.Collisions != 0 {
+		t.Errorf("Expected balanced tokens to be free, got %d collisions", stats.Collisions)
+	}
+	owned := 0
+	for i := 0; i < 4000; i++ {
+		h := uint64(i) * (math.MaxUint64 / 4000)
+		if host, _ := hashed.GetHash(ctx, h); host == "h4" {
+			owned++
+		}
+	}
+	if owned < 800 || owned > 2000 {
+		t.Errorf("Expected h4 to own between a fifth and half of the ring, got %d of 4000", owned)
+	}
+}
+
+func TestTokenSnapshotAndDiff(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ch.AddWithTokens(ctx, "a", []uint64{0})
+	ch.AddWithTokens(ctx, "b", []uint64{1 << 63})
+
+	// Adding a token at a quarter moves the keys up to it from b to c.
+	plan, err := ch.PlanAdd(ctx, "c", HostOptions{Tokens: []uint64{1 << 62}})
+	if err != nil {
+		t.Fatalf("PlanAdd failed: %v", err)
+	}
+	if len(plan.Moves) != 1 || plan.Moves[0].From != "b" || plan.Moves[0].To != "c" {
+		t.Errorf("Expected a single move from b to c, got %+v", plan.Moves)
+	}
+	if plan.Moved() != 0.25 {
+		t.Errorf("Expected a quarter of the ring to move, got %f", plan.Moved())
+	}
+
+	// Snapshots keep explicit tokens, in both encodings.
+	ch.AddWithTokens(ctx, "c", []uint64{1 << 62})
+	ch.Add(ctx, "d")
+	for _, encoding := range []string{"binary", "json"} {
+		var data []byte
+		restored, _ := NewWithConfig(Config{HashFunction: fnv.New64a})
+		if encoding == "binary" {
+			data, _ = ch.MarshalBinary()
+			err = restored.UnmarshalBinary(data)
+		} else {
+			data, _ = ch.MarshalJSON()
+			err = restored.UnmarshalJSON(data)
+		}
+		if err != nil {
+			t.Fatalf("Restoring %s snapshot failed: %v", encoding, err)
+		}
+		if plan := Diff(ch, restored); plan.Moved() != 0 {
+			t.Errorf("Expected restored %s ring to match, got %f moved", encoding, plan.Moved())
+		}
+		if tokens, _ := restored.Tokens("c"); len(tokens) != 1 || tokens[0] != 1<<62 {
+			t.Errorf("Expected restored token %d, got %v", uint64(1<<62), tokens)
+		}
+		if r := restored.ring.Load(); r.hosts[r.index["c"]].Tokens == nil {
+			t.Errorf("Expected c to keep explicit tokens in the %s snapshot", encoding)
+		}
+	}
+}
+
+func TestKetamaTokens(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{Ketama: true})
+	ch.AddHosts(ctx, "a", "b")
+	ch.AddWithTokens(ctx, "c", []uint64{42})
+
+	// Tokenized hosts do not change the ketama points of the other hosts.
+	if stats := ch.Stats(); stats.VNodes != 321 {
+		t.Errorf("Expected 321 vnodes, got %d", stats.VNodes)
+	}
+	if got, _ := ch.GetHash(ctx, 42); got != "c" {
+		t.Errorf("Expected c to own its token, got %s", got)
+	}
+}
@@ -0,0 +1,121 @@
+package consistent_hashing
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+)
+
+// FailureDomain is the topology level at which replicas are spread.
+type FailureDomain int
+
+const (
+	// DomainRegion spreads replicas across distinct regions.
+	DomainRegion FailureDomain = iota
+	// DomainZone spreads replicas across distinct availability zones.
+	DomainZone
+	// DomainRack spreads replicas across distinct racks.
+	DomainRack
+)
+
+// Domain returns the identifier of the failure domain the host belongs to at the given level.
+// Lower levels include their parents, so zones with the same name in different regions differ.
+func (h *Host) Domain(level FailureDomain) string {
+	switch level {
+	case DomainRegion:
+		return h.Region
+	case DomainZone:
+		return h.Region + "/" + h.Zone
+	default:
+		return h.Region + "/" + h.Zone + "/" + h.Rack
+	}
+}
+
+// GetNAcrossDomains retrieves n distinct hosts for the given key, spread across distinct failure
+// domains at the given level when possible. The domains are ranked for the key by rendezvous
+// hashing of their names, and the first host clockwise from the key in each of the n best ranked
+// domains is returned, best ranked domain first. If there are fewer domains than n, the remaining
+// hosts are appended in clockwise order, so the result is deterministic for a given ring.
+// Unhealthy hosts are only used once no healthy host is left, in clockwise order, and a domain
+// without healthy hosts is not ranked.
+// Because the ranking only depends on the domain names, adding a host to an existing domain only
+// changes the replica chosen for its own domain, or one of the fallback replicas. Adding a host in
+// a new domain replaces at most the replica of the worst ranked domain.
+// If n is negative, it returns ErrInvalidReplicaCount. If no hosts are added, it returns
+// ErrNoHost. If n exceeds the number of hosts, it returns ErrInsufficientHosts.
+func (c *ConsistentHashing) GetNAcrossDomains(ctx context.Context, key string, n int, level FailureDomain) ([]string, error) {
+	if n < 0 {
+		return nil, ErrInvalidReplicaCount
+	}
+
+	// Load the current ring, it is never modified after it has been published.
+	r := c.current()
+
+	// Return error if no hosts are added or not enough hosts to satisfy n
+	if len(r.hosts) == 0 {
+		return nil, ErrNoHost
+	}
+	if n > len(r.hosts) {
+		return nil, ErrInsufficientHosts
+	}
+
+	// Generate hash value for the given key using the configured hash function.
+	h, err := r.hash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the first healthy host clockwise in every domain, remembering the others.
+	first := make(map[string]string)
+	var domains, skipped, unhealthy []string
+	r.walkDistinct(r.search(h), func(i int32) bool {
+		host := r.hosts[i]
+		if !host.healthy() {
+			unhealthy = append(unhealthy, host.Name)
+			return true
+		}
+		domain := host.Domain(level)
+		if _, ok := first[domain]; ok {
+			skipped = append(skipped, host.Name)
+			return true
+		}
+		first[domain] = host.Name
+		domains = append(domains, domain)
+		return true
+	})
+
+	// Rank the domains for the key, breaking ties by name.
+	scores := make(map[string]uint64, len(domains))
+	for _, domain := range domains {
+		scores[domain] = domainScore(h, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if scores[domains[i]] != scores[domains[j]] {
+			return scores[domains[i]] > scores[domains[j]]
+		}
+		return domains[i] < domains[j]
+	})
+
+	// Take one host per domain in rank order, then fall back to the hosts in already used
+	// domains and to unhealthy hosts.
+	replicas := make([]string, 0, n)
+	for _, domain := range domains {
+		if len(replicas) == n {
+			break
+		}
+		replicas = append(replicas, first[domain])
+	}
+	for _, host := range append(skipped, unhealthy...) {
+		if len(replicas) == n {
+			break
+		}
+		replicas = append(replicas, host)
+	}
+
+	return replicas, nil
+}
+
+// domainScore returns the rendezvous score of the failure domain for the key hash h.
+func domainScore(h uint64, domain string) uint64 {
+	return mix64(h ^ hashWith(fnv.New64a, domain))
+}
@@ -0,0 +1,150 @@
+package consistent_hashing
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"testing"
+)
+
+func TestHostDomain(t *testing.T) {
+	h := &Host{Name: "host1", Region: "eu", Zone: "a", Rack: "r1"}
+	if got := h.Domain(DomainRegion); got != "eu" {
+		t.Errorf("Expected region eu, got %s", got)
+	}
+	if got := h.Domain(DomainZone); got != "eu/a" {
+		t.Errorf("Expected zone eu/a, got %s", got)
+	}
+	if got := h.Domain(DomainRack); got != "eu/a/r1" {
+		t.Errorf("Expected rack eu/a/r1, got %s", got)
+	}
+}
+
+func TestGetNAcrossDomains(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	zones := []string{"a", "b", "c"}
+	for i := 0; i < 9; i++ {
+		ch.AddWithOptions(ctx, fmt.Sprintf("host%d", i), HostOptions{Region: "eu", Zone: zones[i%3]})
+	}
+
+	zoneOf := func(host string) string {
+		return ch.host(host).Zone
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		replicas, err := ch.GetNAcrossDomains(ctx, key, 3, DomainZone)
+		if err != nil {
+			t.Fatalf("GetNAcrossDomains failed: %v", err)
+		}
+		seen := make(map[string]bool)
+		for _, host := range replicas {
+			if seen[zoneOf(host)] {
+				t.Fatalf("Replicas %v for %s share zone %s", replicas, key, zoneOf(host))
+			}
+			seen[zoneOf(host)] = true
+		}
+	}
+
+	// With more replicas than zones the remaining replicas fall back to used zones.
+	replicas, err := ch.GetNAcrossDomains(ctx, "key1", 5, DomainZone)
+	if err != nil {
+		t.Fatalf("GetNAcrossDomains failed: %v", err)
+	}
+	if len(replicas) != 5 {
+		t.Errorf("Expected 5 replicas, got %v", replicas)
+	}
+}
+
+func TestGetNAcrossDomainsStable(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, LoadFactor: 1.25, HashFunction: fnv.New64a})
+	ctx := context.Background()
+	zones := []string{"a", "b", "c"}
+	for i := 0; i < 9; i++ {
+		ch.AddWithOptions(ctx, fmt.Sprintf("host%d", i), HostOptions{Zone: zones[i%3]})
+	}
+
+	before := make(map[string][]string)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("key%d", i)
+		before[key], _ = ch.GetNAcrossDomains(ctx, key, 3, DomainZone)
+	}
+
+	byZone := func(replicas []string) map[string]string {
+		zones := make(map[string]string)
+		for _, host := range replicas {
+			zones[ch.host(host).Zone] = host
+		}
+		return zones
+	}
+
+	// Adding a host in zone a must not change the replicas in zones b and c.
+	ch.AddWithOptions(ctx, "host9", HostOptions{Zone: "a"})
+	for key, old := range before {
+		replicas, _ := ch.GetNAcrossDomains(ctx, key, 3, DomainZone)
+		oldZones, newZones := byZone(old), byZone(replicas)
+		for _, zone := range []string{"b", "c"} {
+			if oldZones[zone] != newZones[zone] {
+				t.Errorf("Replica in zone %s for %s changed from %v to %v", zone, key, old, replicas)
+			}
+		}
+	}
+}
+
+func TestGetNAcrossDomainsStableFewerReplicasThanDomains(t *testing.T) {
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 100, LoadFactor: 1.25, HashName: "xxhash64"})
+	ctx := context.Background()
+	zones := []string{"a", "b", "c"}
+	for i := 0; i < 9; i++ {
+		ch.AddWithOptions(ctx, fmt.Sprintf("host%d", i), HostOptions{Zone: zones[i%3]})
+	}
+
+	before := make(map[string][]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		before[key], _ = ch.GetNAcrossDomains(ctx, key, 2, DomainZone)
+	}
+
+	// Adding a host in zone a must not change, add or drop replicas in zones b and c.
+	ch.AddWithOptions(ctx, "host9", HostOptions{Zone: "a"})
+	for key, old := range before {
+		replicas, _ := ch.GetNAcrossDomains(ctx, key, 2, DomainZone)
+		before[key] = replicas
+		for _, zone := range []string{"b", "c"} {
+			var oldHost, newHost string
+			for _, host := range old {
+				if ch.host(host).Zone == zone {
+					oldHost = host
+				}
+			}
+			for _, host := range replicas {
+				if ch.host(host).Zone == zone {
+					newHost = host
+				}
+			}
+			if oldHost != newHost {
+				t.Fatalf("Replica in zone %s for %s changed from %v to %v", zone, key, old, replicas)
+			}
+		}
+	}
+
+	// A host in a new zone replaces at most one replica.
+	ch.AddWithOptions(ctx, "host10", HostOptions{Zone: "d"})
+	for key, old := range before {
+		replicas, _ := ch.GetNAcrossDomains(ctx, key, 2, DomainZone)
+		kept := 0
+		for _, host := range replicas {
+			if indexOf(old, host) >= 0 {
+				kept++
+			}
+		}
+		if kept < len(old)-1 {
+			t.Errorf("Expected at most one replica of %s replaced, got %v to %v", key, old, replicas)
+		}
+	}
+
+	if _, err := ch.GetNAcrossDomains(ctx, "key1", -1, DomainZone); err != ErrInvalidReplicaCount {
+		t.Errorf("Expected ErrInvalidReplicaCount, got %v", err)
+	}
+}
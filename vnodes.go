@@ -0,0 +1,99 @@
+package consistent_hashing
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultVNodeScheme is the name of the vnode naming scheme used when Config sets neither
+// VNodeKey nor VNodeScheme.
+const DefaultVNodeScheme = "length-prefixed"
+
+// LegacyVNodeScheme is the name of the vnode naming scheme of rings created before vnode
+// naming became configurable. Snapshots without a scheme name are restored with it.
+const LegacyVNodeScheme = "legacy"
+
+// vnodeSchemes maps names to vnode naming schemes, so the scheme of a ring can be named in
+// config files and recorded in ring snapshots.
+var vnodeSchemes = struct {
+	sync.RWMutex
+	keys map[string]func(host string, i int) string
+}{
+	keys: map[string]func(host string, i int) string{
+		DefaultVNodeScheme: LengthPrefixedVNodeKey,
+		LegacyVNodeScheme:  LegacyVNodeKey,
+	},
+}
+
+// LengthPrefixedVNodeKey derives the key of the i-th virtual node of a host as
+// "<len(host)>:<host>:<i>". The length prefix makes the key unique for every host and index,
+// whatever characters the host name contains.
+func LengthPrefixedVNodeKey(host string, i int) string {
+	buf := make([]byte, 0, len(host)+24)
+	buf = strconv.AppendInt(buf, int64(len(host)), 10)
+	buf = append(append(append(buf, ':'), host...), ':')
+	return string(strconv.AppendInt(buf, int64(i), 10))
+}
+
+// LegacyVNodeKey derives the key of the i-th virtual node of a host as "<host><i>".
+// Keys are ambiguous: host "a1" vnode 0 and host "a" vnode 10 both use "a10".
+// It is only kept for rings persisted with this scheme.
+func LegacyVNodeKey(host string, i int) string {
+	return fmt.Sprintf("%s%d", host, i)
+}
+
+// RegisterVNodeScheme registers a vnode naming scheme under the given name.
+// It returns ErrVNodeSchemeRegistered if the name is already taken.
+func RegisterVNodeScheme(name string, key func(host string, i int) string) error {
+	vnodeSchemes.Lock()
+	defer vnodeSchemes.Unlock()
+
+	if _, ok := vnodeSchemes.keys[name]; ok {
+		return ErrVNodeSchemeRegistered
+	}
+	vnodeSchemes.keys[name] = key
+	return nil
+}
+
+// VNodeSchemeByName returns the vnode naming scheme registered under the given name.
+// It returns ErrUnknownVNodeScheme if no scheme is registered under the name.
+func VNodeSchemeByName(name string) (func(host string, i int) string, error) {
+	vnodeSchemes.RLock()
+	defer vnodeSchemes.RUnlock()
+
+	if key, ok := vnodeSchemes.keys[name]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownVNodeScheme
+}
+
+// VNodeSchemes returns the names of all registered vnode naming schemes in sorted order.
+func VNodeSchemes() []string {
+	vnodeSchemes.RLock()
+	defer vnodeSchemes.RUnlock()
+
+	names := make([]string, 0, len(vnodeSchemes.keys))
+	for name := range vnodeSchemes.keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// vnodeSchemeOf returns the name the scheme is registered under, or an empty string if it
+// is not registered. Schemes are compared by their function pointer.
+func vnodeSchemeOf(key func(host string, i int) string) string {
+	vnodeSchemes.RLock()
+	defer vnodeSchemes.RUnlock()
+
+	ptr := reflect.ValueOf(key).Pointer()
+	for name, fn := range vnodeSchemes.keys {
+		if reflect.ValueOf(fn).Pointer() == ptr {
+			return name
+		}
+	}
+	return ""
+}
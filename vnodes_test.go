@@ -0,0 +1,93 @@
+package consistent_hashing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestVNodeKeys(t *testing.T) {
+	// The legacy scheme is ambiguous, the default one is not.
+	if LegacyVNodeKey("a1", 0) != LegacyVNodeKey("a", 10) {
+		t.Errorf("Expected legacy keys of a1/0 and a/10 to collide")
+	}
+	if LengthPrefixedVNodeKey("a1", 0) == LengthPrefixedVNodeKey("a", 10) {
+		t.Errorf("Expected length-prefixed keys of a1/0 and a/10 to differ")
+	}
+	if key := LengthPrefixedVNodeKey("a:b", 7); key != "3:a:b:7" {
+		t.Errorf("Expected 3:a:b:7, got %s", key)
+	}
+
+	ch, _ := NewWithConfig(Config{})
+	if cfg := ch.ring.Load().config; cfg.VNodeScheme != DefaultVNodeScheme {
+		t.Errorf("Expected %s, got %s", DefaultVNodeScheme, cfg.VNodeScheme)
+	}
+	legacy, _ := NewWithConfig(Config{VNodeKey: LegacyVNodeKey})
+	if cfg := legacy.ring.Load().config; cfg.VNodeScheme != LegacyVNodeScheme {
+		t.Errorf("Expected %s, got %s", LegacyVNodeScheme, cfg.VNodeScheme)
+	}
+	if _, err := NewWithConfig(Config{VNodeScheme: "nope"}); err != ErrUnknownVNodeScheme {
+		t.Errorf("Expected ErrUnknownVNodeScheme, got %v", err)
+	}
+}
+
+func TestCustomVNodeScheme(t *testing.T) {
+	ctx := context.Background()
+	custom := func(host string, i int) string { return fmt.Sprintf("%s/%d", host, i) }
+	if err := RegisterVNodeScheme("test-slash", custom); err != nil {
+		t.Fatalf("RegisterVNodeScheme failed: %v", err)
+	}
+	if err := RegisterVNodeScheme("test-slash", custom); err != ErrVNodeSchemeRegistered {
+		t.Errorf("Expected ErrVNodeSchemeRegistered, got %v", err)
+	}
+
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 3, VNodeScheme: "test-slash"})
+	ch.Add(ctx, "host1")
+	h, _ := ch.Hash("host1/2")
+	if host, _ := ch.GetHash(ctx, h); host != "host1" || !ch.ring.Load().taken(h) {
+		t.Errorf("Expected a virtual node of host1 at the hash of host1/2")
+	}
+
+	// Snapshots record the scheme by name.
+	data, _ := ch.MarshalJSON()
+	var restored ConsistentHashing
+	if err := restored.UnmarshalJSON(data); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	if cfg := restored.ring.Load().config; cfg.VNodeScheme != "test-slash" {
+		t.Errorf("Expected test-slash, got %s", cfg.VNodeScheme)
+	}
+
+	// Unregistered schemes can only be restored into a ring that already uses them.
+	unnamed, _ := NewWithConfig(Config{ReplicationFactor: 3, VNodeKey: func(host string, i int) string { return host + "|" + fmt.Sprint(i) }})
+	unnamed.Add(ctx, "host1")
+	data, _ = unnamed.MarshalJSON()
+	var zero ConsistentHashing
+	if err := zero.UnmarshalJSON(data); err != ErrUnknownVNodeScheme {
+		t.Errorf("Expected ErrUnknownVNodeScheme, got %v", err)
+	}
+	if err := unnamed.Clone().UnmarshalJSON(data); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestLegacySnapshot(t *testing.T) {
+	ctx := context.Background()
+	ch, _ := NewWithConfig(Config{ReplicationFactor: 10, VNodeScheme: LegacyVNodeScheme})
+	ch.AddHosts(ctx, "host1", "host2", "host3")
+
+	// Snapshots taken before vnode naming was configurable have no scheme and format 2.
+	data, _ := ch.MarshalJSON()
+	var raw map[string]json.RawMessage
+	json.Unmarshal(data, &raw)
+	raw["format_version"] = json.RawMessage("2")
+	delete(raw, "vnode_scheme")
+	data, _ = json.Marshal(raw)
+
+	var restored ConsistentHashing
+	if err := restored.UnmarshalJSON(data); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	assertSameRing(t, ch, &restored)
+}